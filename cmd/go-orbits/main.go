@@ -2,7 +2,12 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/asimazbunzel/go-orbits/pkg/io"
 	"github.com/asimazbunzel/go-orbits/pkg/orbits"
@@ -10,44 +15,98 @@ import (
 
 func main () {
 
+   // subcommands
+   if len(os.Args) > 1 && os.Args[1] == "plot" {
+      plotCommand(os.Args[2:])
+      return
+   }
+   if len(os.Args) > 1 && os.Args[1] == "query" {
+      queryCommand(os.Args[2:])
+      return
+   }
+
    // store name of config file from command line argument
    var configFilename string
    flag.StringVar(&configFilename, "config-file", "config.yaml", "Specify name of configuration file")
    flag.StringVar(&configFilename, "C", "config.yaml", "Specify name of configuration file")
+   var resume bool
+   flag.BoolVar(&resume, "resume", false, "Continue a streaming run from its checkpoint")
    flag.Parse()
 
    // get binary configuration previous to kick study
-   b := orbits.InitBinary(configFilename)
+   b, err := orbits.InitBinary(configFilename)
+   if err != nil {
+      fail("MAIN - main.go - main", err)
+   }
    
    // starting logging message
    if b.LogLevel != "none" {
       io.LogInfo("MAIN - main.go - main", "starting orbits study")
    }
 
-   // compute kicks
-   b.ComputeKicks()
-
-   // use CGS units
-   b.ConvertoCGS()
-
-   // orbit configurations after momentum kick
-   b.OrbitsAfterKicks()
+   // go on from where a previous run was stopped
+   if resume {
+      err = b.LoadCheckpoint(b.CheckpointFilename)
+      if err != nil {
+         fail("MAIN - main.go - main", err)
+      }
+   }
 
-   // compute grid of orbital parameters
-   b.GridOfOrbits()
+   // a run stopped by a signal (e.g. pre-emption of a job) writes a checkpoint, if set
+   ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+   defer stop()
 
-   // go back to astro units
-   b.ConvertoAstro()
+   // kicks, their orbit configurations and grids, back in astro units
+   // runs that do not converge are still saved, with the status of their error
+   err = b.Run(ctx)
+   var convergenceErr *orbits.ConvergenceError
+   if errors.As(err, &convergenceErr) {
+      io.LogError("MAIN - main.go - main", err.Error())
+   } else if err != nil {
+      fail("MAIN - main.go - main", err)
+   }
 
-   // saves to files
-   if b.StoreKicks {
-      b.SaveKicks(b.KicksFilename)
+   // saves to files, every one is tried and the first error is reported at the end
+   var saveErrors []error
+   save := func (err error) {
+      if err != nil {
+         io.LogError("MAIN - main.go - main", err.Error())
+         saveErrors = append(saveErrors, err)
+      }
    }
-   if b.StoreOrbits {
-      b.SaveBoundedOrbits(b.BoundedBinariesFilename)
+   // streaming runs write kicks & orbits while they are made
+   if b.StoreKicks && !b.Streaming {
+      save(b.SaveKicks(b.KicksFilename))
+   }
+   if b.StoreOrbits && !b.Streaming {
+      save(b.SaveBoundedOrbits(b.BoundedBinariesFilename))
    }
    if b.StoreGrid {
-      b.SaveGridOrbits(b.GridFilename)
+      save(b.SaveGridOrbits(b.GridFilename))
+   }
+   if b.StoreGridMatrix {
+      save(b.SaveGridMatrix(b.GridMatrixFilename))
+   }
+   if b.StoreMultiGrid {
+      save(b.SaveMultiGrid(b.MultiGridFilename))
+      save(b.SaveMarginals(b.MarginalsFilename))
+   }
+   if b.StoreRepresentativeOrbits {
+      save(b.SaveRepresentativeOrbits(b.RepresentativeOrbitsFilename))
+   }
+   if b.StoreSurvivalMaps {
+      save(b.SaveSurvivalMap(b.KickThetaMapFilename, b.KickThetaMapFormat, b.KickThetaMap, "w", "theta"))
+      save(b.SaveSurvivalMap(b.ThetaPhiMapFilename, b.ThetaPhiMapFormat, b.ThetaPhiMap, "theta", "phi"))
+      save(b.SaveSurvivalBoundary(b.SurvivalBoundaryFilename))
+   }
+   if b.StoreDatabase {
+      save(b.SaveDatabase(b.DatabaseFilename))
+   }
+   if len(saveErrors) > 0 {
+      os.Exit(exitCode(saveErrors[0]))
+   }
+   if convergenceErr != nil {
+      os.Exit(exitCode(convergenceErr))
    }
 
    // end of computation
@@ -56,3 +115,50 @@ func main () {
    }
 
 }
+
+
+// exit status of each kind of error
+const (
+   exitError = 1
+   exitConfig = 2
+   exitDistribution = 3
+   exitIO = 4
+   exitGrid = 5
+   exitConvergence = 6
+)
+
+
+// exit status for an error, according to its kind
+func exitCode (err error) int {
+
+   var configErr *orbits.ConfigError
+   var distributionErr *orbits.DistributionError
+   var ioErr *orbits.IOError
+   var gridErr *orbits.GridError
+   var convergenceErr *orbits.ConvergenceError
+
+   switch {
+   case errors.As(err, &configErr):
+      return exitConfig
+   case errors.As(err, &distributionErr):
+      return exitDistribution
+   case errors.As(err, &ioErr):
+      return exitIO
+   case errors.As(err, &gridErr):
+      return exitGrid
+   case errors.As(err, &convergenceErr):
+      return exitConvergence
+   }
+
+   return exitError
+
+}
+
+
+// log an error and exit with its status
+func fail (reference string, err error) {
+
+   io.LogError(reference, err.Error())
+   os.Exit(exitCode(err))
+
+}
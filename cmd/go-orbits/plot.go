@@ -0,0 +1,142 @@
+package main
+
+import (
+   "flag"
+   "math"
+   "os"
+   "path/filepath"
+
+   "github.com/asimazbunzel/go-orbits/pkg/io"
+   "github.com/asimazbunzel/go-orbits/pkg/orbits"
+   "github.com/asimazbunzel/go-orbits/pkg/plots"
+)
+
+
+// number of bins of histograms & points of scatter plots in corner plots
+const (
+   plotBins = 50
+   cornerMaxPoints = 5000
+)
+
+
+// `plot` subcommand: figures of kicks, bounded orbits and grid of orbits, read from the files
+// set in the configuration
+func plotCommand (args []string) {
+
+   var configFilename, format, outputDir string
+   fs := flag.NewFlagSet("plot", flag.ExitOnError)
+   fs.StringVar(&configFilename, "config-file", "config.yaml", "Specify name of configuration file")
+   fs.StringVar(&configFilename, "C", "config.yaml", "Specify name of configuration file")
+   fs.StringVar(&format, "format", "png", "Format of figures: png, svg or pdf")
+   fs.StringVar(&outputDir, "output-dir", ".", "Directory where figures are saved")
+   fs.Parse(args)
+
+   b, err := orbits.InitBinary(configFilename)
+   if err != nil {
+      fail("MAIN - plot.go - plotCommand", err)
+   }
+
+   if b.LogLevel != "none" {
+      io.LogInfo("MAIN - plot.go - plotCommand", "making figures in " + format + " format")
+   }
+
+   err = os.MkdirAll(outputDir, 0755)
+   if err != nil {
+      fail("MAIN - plot.go - plotCommand", &orbits.IOError{Filename: outputDir, Op: "create", Err: err})
+   }
+
+   // every figure is tried, and the status of the first error is the exit status
+   var firstErr error
+   report := func (msg string, err error) {
+      io.LogError("MAIN - plot.go - plotCommand", msg + ": " + err.Error())
+      if firstErr == nil {
+         firstErr = err
+      }
+   }
+   figure := func (name string) string {
+      return filepath.Join(outputDir, name + "." + format)
+   }
+
+   // distribution of kicks
+   kicks, err := orbits.ReadColumns(b.KicksFilename, b.KicksFormat)
+   if err != nil {
+      report("unable to read kicks", err)
+   } else {
+      for _, name := range []string{"w", "theta", "phi"} {
+         p, err := plots.Histogram(kicks[name], kicks["weight"], plotBins, name)
+         if err == nil {
+            err = plots.Save(p, figure("kicks_" + name))
+         }
+         if err != nil {
+            report("unable to plot kicks", err)
+         }
+      }
+   }
+
+   // bounded orbits, and corner plot of kicks & orbits
+   bounded, err := orbits.ReadColumns(b.BoundedBinariesFilename, b.BoundedOrbitsFormat)
+   if err != nil {
+      report("unable to read bounded orbits", err)
+   } else {
+      p, err := plots.Scatter(bounded["period"], bounded["eccentricity"], "period [days]", "eccentricity", true)
+      if err == nil {
+         err = plots.Save(p, figure("orbits"))
+      }
+      if err != nil {
+         report("unable to plot bounded orbits", err)
+      }
+
+      logP := make([]float64, len(bounded["period"]))
+      for k, period := range bounded["period"] {
+         logP[k] = math.Log10(period)
+      }
+      columns := [][]float64{bounded["w"], bounded["theta"], bounded["phi"], logP, bounded["eccentricity"]}
+      names := []string{"w [km/s]", "theta", "phi", "log10(period)", "eccentricity"}
+      corner, err := plots.Corner(columns, bounded["weight"], names, plotBins, cornerMaxPoints)
+      if err == nil {
+         err = plots.SaveTable(corner, figure("corner"), format)
+      }
+      if err != nil {
+         report("unable to make corner plot", err)
+      }
+   }
+
+   // cells of the grid above minimum probability
+   grid, err := orbits.ReadColumns(b.GridFilename, b.GridFormat)
+   if err != nil {
+      report("unable to read grid of orbits", err)
+   } else {
+      p, err := plots.Scatter(grid["period"], grid["eccentricity"], "period [days]", "eccentricity", true)
+      if err == nil {
+         err = plots.Save(p, figure("grid"))
+      }
+      if err != nil {
+         report("unable to plot grid of orbits", err)
+      }
+   }
+
+   // heatmap of every cell of the grid, in log10(period)
+   if b.StoreGridMatrix {
+      m, err := orbits.ReadGridMatrix(b.GridMatrixFilename)
+      if err != nil {
+         report("unable to read matrix of grid", err)
+         os.Exit(exitCode(firstErr))
+      }
+      logEdges := make([]float64, len(m.PeriodEdges))
+      for k, period := range m.PeriodEdges {
+         logEdges[k] = math.Log10(period)
+      }
+      p, err := plots.Heatmap(logEdges, m.EccentricityEdges, m.Probability, "log10(period / days)", "eccentricity")
+      if err == nil {
+         err = plots.Save(p, figure("grid_heatmap"))
+      }
+      if err != nil {
+         report("unable to plot heatmap of grid", err)
+      }
+   }
+
+   if firstErr != nil {
+      os.Exit(exitCode(firstErr))
+   }
+
+}
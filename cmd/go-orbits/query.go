@@ -0,0 +1,68 @@
+package main
+
+import (
+   "flag"
+   "fmt"
+   "os"
+   "strconv"
+
+   "github.com/asimazbunzel/go-orbits/pkg/io"
+   "github.com/asimazbunzel/go-orbits/pkg/orbits"
+)
+
+
+// `query` subcommand: runs stored in a database that match every filter given as argument, e.g.
+// orbits query -database orbits.db "kick_sigma < 100" "bounded_fraction > 0.5"
+func queryCommand (args []string) {
+
+   var databaseFilename string
+   fs := flag.NewFlagSet("query", flag.ExitOnError)
+   fs.StringVar(&databaseFilename, "database", "orbits.db", "Specify name of database file")
+   fs.StringVar(&databaseFilename, "d", "orbits.db", "Specify name of database file")
+   fs.Parse(args)
+
+   var filters []orbits.RunFilter
+   for _, arg := range fs.Args() {
+      f, err := orbits.ParseRunFilter(arg)
+      if err != nil {
+         io.LogError("MAIN - query.go - queryCommand", err.Error())
+         os.Exit(1)
+      }
+      filters = append(filters, f)
+   }
+
+   // sqlite would create an empty database
+   if _, err := os.Stat(databaseFilename); err != nil {
+      fail("MAIN - query.go - queryCommand", &orbits.IOError{Filename: databaseFilename, Op: "open database", Err: err})
+   }
+
+   runs, err := orbits.QueryRuns(databaseFilename, filters)
+   if err != nil {
+      fail("MAIN - query.go - queryCommand", &orbits.IOError{Filename: databaseFilename, Op: "query database", Err: err})
+   }
+
+   // summary of each run, followed by the options used in filters
+   var options []string
+   for _, f := range filters {
+      if !f.OnRuns() {
+         options = append(options, f.Name)
+      }
+   }
+   header := fmt.Sprintf("%6s%22s%30s%16s%20s", "id", "timestamp", "config_file", "kicks", "bounded_fraction")
+   for _, name := range options {
+      header += fmt.Sprintf("%24s", name)
+   }
+   fmt.Println(header)
+   for _, r := range runs {
+      str := fmt.Sprintf("%6d%22s%30s%16d%20s", r.ID, r.Timestamp, r.ConfigFile, r.NumberOfKicks, strconv.FormatFloat(r.BoundedFraction, 'E', 5, 64))
+      for _, name := range options {
+         value, ok := r.Options[name]
+         if !ok {
+            value = "-"
+         }
+         str += fmt.Sprintf("%24s", value)
+      }
+      fmt.Println(str)
+   }
+
+}
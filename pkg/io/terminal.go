@@ -0,0 +1,126 @@
+package io
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/TwiN/go-color"
+)
+
+// shades from low to high values, with unicode blocks or plain ASCII
+var (
+	unicodeBars   = []rune("▁▂▃▄▅▆▇█")
+	unicodeShades = []rune(" ░▒▓█")
+	asciiShades   = []rune(" .:-=+*#%@")
+	heatColors    = []string{color.Blue, color.Cyan, color.Green, color.Yellow, color.Red}
+)
+
+// ColorSupported tells whether the terminal can show colours and unicode: stdout must be a
+// terminal, TERM must not be "dumb" and NO_COLOR must not be set
+func ColorSupported() bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	term := os.Getenv("TERM")
+	if term == "" || term == "dumb" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// level of a value between 0 and max, as an index in [0, n)
+func level(x, max float64, n int) int {
+	if max <= 0 || x <= 0 {
+		return 0
+	}
+	k := int(x / max * float64(n-1) + 0.5)
+	if k >= n {
+		k = n - 1
+	}
+	return k
+}
+
+// Sparkline of values, one character per value scaled to the maximum
+func Sparkline(values []float64) string {
+	max := 0.0
+	for _, x := range values {
+		if x > max {
+			max = x
+		}
+	}
+	shades := unicodeBars
+	if !ColorSupported() {
+		shades = asciiShades
+	}
+	var sb strings.Builder
+	for _, x := range values {
+		sb.WriteRune(shades[level(x, max, len(shades))])
+	}
+	return sb.String()
+}
+
+// Heatmap of a matrix, with the first row at the bottom (as in a plot) and each cell two
+// characters wide. Rows and columns are labelled with rowLabels & colLabels (first & last are
+// enough for columns)
+func Heatmap(matrix [][]float64, rowLabels []string, colLabels []string) string {
+	max := 0.0
+	for _, row := range matrix {
+		for _, x := range row {
+			if x > max {
+				max = x
+			}
+		}
+	}
+	useColor := ColorSupported()
+
+	width := 0
+	for _, label := range rowLabels {
+		if len(label) > width {
+			width = len(label)
+		}
+	}
+
+	var sb strings.Builder
+	for i := len(matrix) - 1; i >= 0; i-- {
+		label := ""
+		if i < len(rowLabels) {
+			label = rowLabels[i]
+		}
+		sb.WriteString(fmt.Sprintf("%*s |", width, label))
+		for _, x := range matrix[i] {
+			if useColor {
+				if x <= 0 {
+					sb.WriteString("  ")
+					continue
+				}
+				shade := string(unicodeShades[level(x, max, len(unicodeShades)-1)+1])
+				sb.WriteString(color.Ize(heatColors[level(x, max, len(heatColors))], shade+shade))
+			} else {
+				shade := string(asciiShades[level(x, max, len(asciiShades))])
+				sb.WriteString(shade + shade)
+			}
+		}
+		sb.WriteString("|\n")
+	}
+
+	if len(colLabels) > 0 {
+		nCols := 0
+		if len(matrix) > 0 {
+			nCols = len(matrix[0])
+		}
+		first := colLabels[0]
+		last := colLabels[len(colLabels)-1]
+		gap := 2*nCols - len(first) - len(last)
+		if gap < 1 {
+			gap = 1
+		}
+		sb.WriteString(fmt.Sprintf("%*s  %s%s%s\n", width, "", first, strings.Repeat(" ", gap), last))
+	}
+
+	return sb.String()
+}
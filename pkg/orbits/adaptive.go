@@ -0,0 +1,182 @@
+package orbits
+
+import (
+   "math"
+
+   "github.com/asimazbunzel/go-orbits/pkg/units"
+
+   "gonum.org/v1/gonum/floats"
+)
+
+
+// cell of an adaptive grid, with borders in period & eccentricity and the index (in the
+// bounded slices) of the binaries inside it. Cells at the upper borders of the grid include them
+type gridCell struct {
+   pMin, pMax float64
+   eMin, eMax float64
+   pClosed, eClosed bool
+   depth int
+   members []int
+}
+
+
+// grid of cells recursively split in four (quadtree), halving them in period & eccentricity (in
+// PeriodScale & EccentricityScale), until each cell holds at most AdaptiveMaxProbability of the bounded binaries or
+// AdaptiveMaxSamples of them (whichever is set), or has been split AdaptiveMaxDepth times. The
+// representative orbit of each cell is the weighted mean of its binaries (geometric in period).
+// Cells above a minimum probability are kept. It returns the probability of all cells, including
+// those below the minimum
+func (b *Binary) adaptiveGridOfOrbits (pMin float64, pMax float64, eMin float64, eMax float64) (float64, error) {
+
+   if b.AdaptiveMaxProbability <= 0 && b.AdaptiveMaxSamples <= 0 {
+      return 0, &ConfigError{Option: "adaptive_max_probability", Message: "either `adaptive_max_probability` or `adaptive_max_samples` must be greater than 0"}
+   }
+
+   totalWeight := floats.Sum(b.WeightBounded)
+
+   root := gridCell{pMin: pMin, pMax: pMax, eMin: eMin, eMax: eMax, pClosed: true, eClosed: true}
+   for k, _ := range b.IndexBounded {
+      if root.contains(b.PeriodBounded[k], b.EccentricityBounded[k]) {
+         root.members = append(root.members, k)
+      }
+   }
+
+   // split cells until none needs it, depth first so that neighbouring cells stay together
+   var leaves []gridCell
+   stack := []gridCell{root}
+   for len(stack) > 0 {
+      cell := stack[len(stack)-1]
+      stack = stack[:len(stack)-1]
+      if len(cell.members) == 0 {
+         continue
+      }
+      if b.needsSplit(cell, totalWeight) {
+         children := cell.split(b.PeriodBounded, b.EccentricityBounded, b.PeriodScale, b.EccentricityScale)
+         for k := len(children)-1; k >= 0; k-- {
+            stack = append(stack, children[k])
+         }
+      } else {
+         leaves = append(leaves, cell)
+      }
+   }
+
+   // probability of each cell and the cell of each bounded binary, needed for intervals
+   probabilities := make([]float64, len(leaves))
+   cells := make([]int, len(b.IndexBounded))
+   for k, _ := range cells {
+      cells[k] = -1
+   }
+   for c, cell := range leaves {
+      for _, k := range cell.members {
+         probabilities[c] += b.WeightBounded[k] / totalWeight
+         cells[k] = c
+      }
+   }
+   intervals := b.cellIntervals(probabilities, cells)
+
+   for c, cell := range leaves {
+      if probabilities[c] <= b.MinProb {
+         continue
+      }
+      logP := 0.0
+      e := 0.0
+      weight := 0.0
+      for _, k := range cell.members {
+         logP += b.WeightBounded[k] * math.Log10(b.PeriodBounded[k])
+         e += b.WeightBounded[k] * b.EccentricityBounded[k]
+         weight += b.WeightBounded[k]
+      }
+      p := math.Pow(10.0, logP / weight)
+
+      b.PeriodGrid = append(b.PeriodGrid, p)
+      b.EccentricityGrid = append(b.EccentricityGrid, e / weight)
+      b.SeparationGrid = append(b.SeparationGrid, float64(PtoA(units.Time(p), b.massCO(), b.mass2())))
+      b.PeriodLowerGrid = append(b.PeriodLowerGrid, cell.pMin)
+      b.PeriodUpperGrid = append(b.PeriodUpperGrid, cell.pMax)
+      b.EccentricityLowerGrid = append(b.EccentricityLowerGrid, cell.eMin)
+      b.EccentricityUpperGrid = append(b.EccentricityUpperGrid, cell.eMax)
+      b.ProbabilityGrid = append(b.ProbabilityGrid, probabilities[c])
+      b.ProbabilityLowerGrid = append(b.ProbabilityLowerGrid, intervals[c][0])
+      b.ProbabilityUpperGrid = append(b.ProbabilityUpperGrid, intervals[c][1])
+   }
+
+   return floats.Sum(probabilities), nil
+
+}
+
+
+// whether a cell holds more than the target probability or number of binaries
+func (b *Binary) needsSplit (cell gridCell, totalWeight float64) bool {
+
+   if cell.depth >= b.AdaptiveMaxDepth || len(cell.members) < 2 {
+      return false
+   }
+
+   if b.AdaptiveMaxSamples > 0 && len(cell.members) > b.AdaptiveMaxSamples {
+      return true
+   }
+
+   if b.AdaptiveMaxProbability > 0 {
+      weight := 0.0
+      for _, k := range cell.members {
+         weight += b.WeightBounded[k]
+      }
+      if weight / totalWeight > b.AdaptiveMaxProbability {
+         return true
+      }
+   }
+
+   return false
+
+}
+
+
+// whether an orbit is inside a cell, upper borders not included unless the cell is closed
+func (c gridCell) contains (p float64, e float64) bool {
+
+   pInside := p >= c.pMin && (p < c.pMax || (c.pClosed && p == c.pMax))
+   eInside := e >= c.eMin && (e < c.eMax || (c.eClosed && e == c.eMax))
+
+   return pInside && eInside
+
+}
+
+
+// split a cell in four, at the middle of period & eccentricity in their scales
+func (c gridCell) split (period []float64, eccentricity []float64, pScale string, eScale string) [4]gridCell {
+
+   pMid := middle(c.pMin, c.pMax, pScale)
+   eMid := middle(c.eMin, c.eMax, eScale)
+
+   // only children at the upper borders inherit closed borders
+   children := [4]gridCell{
+      {pMin: c.pMin, pMax: pMid, eMin: c.eMin, eMax: eMid, depth: c.depth + 1},
+      {pMin: pMid, pMax: c.pMax, eMin: c.eMin, eMax: eMid, pClosed: c.pClosed, depth: c.depth + 1},
+      {pMin: c.pMin, pMax: pMid, eMin: eMid, eMax: c.eMax, eClosed: c.eClosed, depth: c.depth + 1},
+      {pMin: pMid, pMax: c.pMax, eMin: eMid, eMax: c.eMax, pClosed: c.pClosed, eClosed: c.eClosed, depth: c.depth + 1},
+   }
+
+   for _, k := range c.members {
+      for l, _ := range children {
+         if children[l].contains(period[k], eccentricity[k]) {
+            children[l].members = append(children[l].members, k)
+            break
+         }
+      }
+   }
+
+   return children
+
+}
+
+
+// middle of an interval, geometric in log scale
+func middle (min float64, max float64, scale string) float64 {
+
+   if scale == "log" {
+      return math.Sqrt(min * max)
+   }
+
+   return 0.5 * (min + max)
+
+}
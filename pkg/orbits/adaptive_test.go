@@ -0,0 +1,80 @@
+package orbits
+
+import (
+   "context"
+   "math"
+   "testing"
+
+   "gonum.org/v1/gonum/floats"
+)
+
+
+// position of x between min & max in a scale, from 0 to 1
+func scaledPosition (x float64, min float64, max float64, scale string) float64 {
+
+   if scale == "log" {
+      return math.Log(x / min) / math.Log(max / min)
+   }
+
+   return (x - min) / (max - min)
+
+}
+
+
+// adaptive cells are halves of the borders in their scales, every bounded binary inside the
+// borders is in one cell and probabilities of cells sum to those of the grid
+func TestAdaptiveGridCells (t *testing.T) {
+
+   for _, scale := range []string{"log", "linear"} {
+      cfg := testConfig(20000)
+      cfg.GridMethod, cfg.PeriodScale = "adaptive", scale
+      cfg.AdaptiveMaxProbability, cfg.MinProb = 0.05, 0
+      b := NewBinary(cfg)
+      err := b.Run(context.Background())
+      if err != nil {
+         t.Fatal(err)
+      }
+
+      pMin, pMax := b.PeriodBordersGrid[0], b.PeriodBordersGrid[1]
+      eMin, eMax := b.EccentricityBordersGrid[0], b.EccentricityBordersGrid[1]
+
+      // width of each cell is 1/2^depth of the borders, in the scale of each axis
+      for c, _ := range b.ProbabilityGrid {
+         pWidth := scaledPosition(b.PeriodUpperGrid[c], pMin, pMax, scale) - scaledPosition(b.PeriodLowerGrid[c], pMin, pMax, scale)
+         eWidth := scaledPosition(b.EccentricityUpperGrid[c], eMin, eMax, "linear") - scaledPosition(b.EccentricityLowerGrid[c], eMin, eMax, "linear")
+         for _, w := range []float64{pWidth, eWidth} {
+            if depth := -math.Log2(w); math.Abs(depth - math.Round(depth)) > 1e-9 {
+               t.Errorf("%s: cell %d has a width of %f of the borders, not a power of 1/2", scale, c, w)
+            }
+         }
+      }
+
+      // every bounded binary inside the borders is in exactly one cell
+      inside := 0.0
+      for k, p := range b.PeriodBounded {
+         e := b.EccentricityBounded[k]
+         if p < pMin || p > pMax || e < eMin || e > eMax {
+            continue
+         }
+         inside += b.WeightBounded[k]
+         n := 0
+         for c, _ := range b.ProbabilityGrid {
+            pIn := p >= b.PeriodLowerGrid[c] && (p < b.PeriodUpperGrid[c] || p == b.PeriodUpperGrid[c] && p == pMax)
+            eIn := e >= b.EccentricityLowerGrid[c] && (e < b.EccentricityUpperGrid[c] || e == b.EccentricityUpperGrid[c] && e == eMax)
+            if pIn && eIn {
+               n++
+            }
+         }
+         if n != 1 {
+            t.Fatalf("%s: bounded binary %d (P=%e, e=%f) is in %d cells", scale, k, p, e, n)
+         }
+      }
+
+      inside /= floats.Sum(b.WeightBounded)
+      total := floats.Sum(b.ProbabilityGrid)
+      if math.Abs(total - inside) > 1e-9 || math.Abs(total + b.ProbabilityOutsideGrid - 1) > 1e-9 {
+         t.Errorf("%s: cells sum to %f with %f outside, want %f inside and a total of 1", scale, total, b.ProbabilityOutsideGrid, inside)
+      }
+   }
+
+}
@@ -0,0 +1,252 @@
+package orbits
+
+import (
+   "context"
+   "encoding/json"
+   "io/ioutil"
+   "os"
+
+   "github.com/asimazbunzel/go-orbits/pkg/io"
+)
+
+
+// progress of a streaming run: its pass (1 draws, writes & sums kicks, 2 draws them again to fill
+// the grid), the index of the next kick of the pass, and the borders & histogram of the grid
+type streamState struct {
+   pass int
+   next int
+   pBorders []float64
+   eBorders []float64
+   histogram [][]float64
+}
+
+
+// checkpoint of a streaming run, in JSON. Random streams are set by the seed and the index of
+// each chunk of kicks, so NextKick is all the state of the random number generator. Outputs are
+// valid up to their offsets (in bytes), later rows are written again when resuming
+type checkpoint struct {
+   ConfigHash string `json:"config_hash"`
+   OptionsHash string `json:"options_hash"`
+   Timestamp string `json:"timestamp"`
+   Pass int `json:"pass"`
+   NextKick int `json:"next_kick"`
+
+   Kicks int `json:"number_of_kicks"`
+   Bounded int `json:"number_of_bounded"`
+   Weight float64 `json:"weight"`
+   Weight2 float64 `json:"weight_squared"`
+   WeightBounded float64 `json:"weight_bounded"`
+   WeightBounded2 float64 `json:"weight_bounded_squared"`
+   Period *TDigest `json:"period_sketch"`
+   Eccentricity *TDigest `json:"eccentricity_sketch"`
+
+   PeriodBorders []float64 `json:"period_borders"`
+   EccentricityBorders []float64 `json:"eccentricity_borders"`
+   Histogram [][]float64 `json:"histogram"`
+
+   KicksOffset int64 `json:"kicks_offset"`
+   OrbitsOffset int64 `json:"bounded_orbits_offset"`
+}
+
+
+// options that change neither kicks nor results, so they can differ between a run and its resume
+var resumableOptions = map[string]bool{
+   "workers": true,
+   "log_level": true,
+   "terminal_plots": true,
+   "checkpoint_filename": true,
+   "checkpoint_interval": true,
+}
+
+
+// hash of the options that set the results of a run, which a checkpoint must have been made with
+func (b *Binary) optionsHash () string {
+
+   var keywords []Keyword
+   for _, kw := range b.ConfigKeywords() {
+      if !resumableOptions[kw.Name] {
+         keywords = append(keywords, kw)
+      }
+   }
+
+   return configHash(keywords)
+
+}
+
+
+// read the checkpoint of a streaming run, which goes on from it the next time it is run. Runs in
+// memory (convergence runs included) are not checkpointed: they are run again, drawing the same
+// kicks from the seed
+func (b *Binary) LoadCheckpoint (filename string) error {
+
+   if b.LogLevel != "none" {
+      io.LogInfo("ORBITS - checkpoint.go - LoadCheckpoint", "loading checkpoint from " + filename)
+   }
+
+   if !b.Streaming {
+      return &ConfigError{Option: "streaming", Message: "only streaming runs can be resumed from a checkpoint, runs in memory are run again"}
+   }
+   if filename == "" {
+      return &ConfigError{Option: "checkpoint_filename", Message: "no checkpoint file to resume from"}
+   }
+
+   data, err := ioutil.ReadFile(filename)
+   if err != nil {
+      return &IOError{Filename: filename, Op: "read", Err: err}
+   }
+   var c checkpoint
+   err = json.Unmarshal(data, &c)
+   if err != nil {
+      return &IOError{Filename: filename, Op: "parse", Err: err}
+   }
+   if c.OptionsHash != b.optionsHash() {
+      return &ConfigError{Option: "checkpoint_filename", Message: "checkpoint " + filename + " was made with other options (only workers, log_level, terminal_plots and checkpoint_* can change)"}
+   }
+
+   b.resume = &c
+
+   return nil
+
+}
+
+
+// check the options of checkpoints, which are only written by streaming runs (see LoadCheckpoint)
+func (b *Binary) checkCheckpointOptions () error {
+
+   if b.CheckpointFilename == "" {
+      return nil
+   }
+   if !b.Streaming {
+      return &ConfigError{Option: "checkpoint_filename", Message: "checkpoints are only written by streaming runs, runs in memory (convergence runs included) are run again"}
+   }
+   if b.CheckpointInterval < 0 {
+      return &ConfigError{Option: "checkpoint_interval", Message: "number of batches between checkpoints cannot be negative"}
+   }
+
+   return nil
+
+}
+
+
+// whether a checkpoint is due once a batch is done, with next as the index of the next kick. They
+// are written every CheckpointInterval batches (every batch if 0), except after the last one
+func (b *Binary) checkpointDue (next int) bool {
+
+   if b.CheckpointFilename == "" || next >= b.NumberOfCases {
+      return false
+   }
+   interval := b.CheckpointInterval
+   if interval <= 0 {
+      interval = 1
+   }
+
+   return (next / b.streamBatchSize()) % interval == 0
+
+}
+
+
+// write a checkpoint of the state of a streaming run, once its outputs are on disk. The previous
+// checkpoint is only replaced when the new one is complete
+func (b *Binary) saveCheckpoint (s streamState, kicks *tableStream, orbits *tableStream) error {
+
+   c := checkpoint{
+      ConfigHash: b.ConfigHash,
+      OptionsHash: b.optionsHash(),
+      Timestamp: b.Timestamp,
+      Pass: s.pass,
+      NextKick: s.next,
+      Kicks: b.totals.kicks,
+      Bounded: b.totals.bounded,
+      Weight: b.totals.weight,
+      Weight2: b.totals.weight2,
+      WeightBounded: b.totals.weightBounded,
+      WeightBounded2: b.totals.weightBounded2,
+      Period: b.totals.period,
+      Eccentricity: b.totals.eccentricity,
+      PeriodBorders: s.pBorders,
+      EccentricityBorders: s.eBorders,
+      Histogram: s.histogram,
+   }
+
+   var err error
+   if kicks != nil {
+      c.KicksOffset, err = kicks.sync()
+      if err != nil {
+         return err
+      }
+   }
+   if orbits != nil {
+      c.OrbitsOffset, err = orbits.sync()
+      if err != nil {
+         return err
+      }
+   }
+
+   data, err := json.Marshal(c)
+   if err != nil {
+      return &IOError{Filename: b.CheckpointFilename, Op: "encode", Err: err}
+   }
+   tmp := b.CheckpointFilename + ".tmp"
+   err = ioutil.WriteFile(tmp, data, 0644)
+   if err == nil {
+      err = os.Rename(tmp, b.CheckpointFilename)
+   }
+   if err != nil {
+      return &IOError{Filename: b.CheckpointFilename, Op: "write", Err: err}
+   }
+
+   if b.LogLevel == "debug" {
+      io.LogInfo("ORBITS - checkpoint.go - saveCheckpoint", "checkpoint saved to " + b.CheckpointFilename)
+   }
+
+   return nil
+
+}
+
+
+// state of a streaming run from its checkpoint, also setting the totals of b. Outputs keep the
+// provenance (timestamp & config hash) of the run that was stopped
+func (b *Binary) restoreCheckpoint (c *checkpoint) streamState {
+
+   b.Timestamp, b.ConfigHash = c.Timestamp, c.ConfigHash
+   b.totals = &streamTotals{
+      kicks: c.Kicks,
+      bounded: c.Bounded,
+      weight: c.Weight,
+      weight2: c.Weight2,
+      weightBounded: c.WeightBounded,
+      weightBounded2: c.WeightBounded2,
+      period: c.Period,
+      eccentricity: c.Eccentricity,
+   }
+   if b.totals.period == nil {
+      b.totals.period = NewTDigest(tdigestCompression)
+   }
+   if b.totals.eccentricity == nil {
+      b.totals.eccentricity = NewTDigest(tdigestCompression)
+   }
+
+   return streamState{pass: c.Pass, next: c.NextKick, pBorders: c.PeriodBorders, eBorders: c.EccentricityBorders, histogram: c.Histogram}
+
+}
+
+
+// error of a streaming run that stopped before its end. If it was stopped through its context
+// (e.g. the job was pre-empted), a checkpoint is written so that it can be resumed
+func (b *Binary) interrupted (ctx context.Context, err error, s streamState, kicks *tableStream, orbits *tableStream) error {
+
+   if b.CheckpointFilename == "" || ctx.Err() == nil || err != ctx.Err() {
+      return err
+   }
+
+   saveErr := b.saveCheckpoint(s, kicks, orbits)
+   if saveErr != nil {
+      return saveErr
+   }
+   if b.LogLevel != "none" {
+      io.LogInfo("ORBITS - checkpoint.go - interrupted", "run stopped, checkpoint saved to " + b.CheckpointFilename + ", use --resume to go on")
+   }
+
+   return err
+
+}
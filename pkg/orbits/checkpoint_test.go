@@ -0,0 +1,134 @@
+package orbits
+
+import (
+   "bytes"
+   "context"
+   "io/ioutil"
+   "path/filepath"
+   "reflect"
+   "testing"
+)
+
+
+// context that is cancelled after its error is checked a number of times, i.e. a run stopped
+// after a number of batches
+type stopAfterContext struct {
+   context.Context
+   checks int
+}
+
+func (c *stopAfterContext) Err () error {
+
+   if c.checks <= 0 {
+      return context.Canceled
+   }
+   c.checks--
+
+   return nil
+
+}
+
+
+// options of a streaming run in batches of one chunk, saving kicks & orbits in dir. Grid borders
+// come from quantiles, so the run makes two passes over its kicks
+func streamingTestConfig (dir string) Config {
+
+   cfg := testConfig(5 * kickChunk + 321)
+   cfg.Streaming = true
+   cfg.StreamBatchSize = kickChunk
+   cfg.StoreKicks, cfg.StoreOrbits = true, true
+   cfg.KicksFilename = filepath.Join(dir, "kicks.data")
+   cfg.BoundedBinariesFilename = filepath.Join(dir, "orbits.data")
+   cfg.KicksFormat, cfg.BoundedOrbitsFormat = "text", "text"
+   cfg.CheckpointFilename = filepath.Join(dir, "checkpoint.json")
+   cfg.CheckpointInterval = 2
+
+   return cfg
+
+}
+
+
+// outputs & results of a streaming run in dir, stopped (if stop >= 0) after stop batches and
+// resumed with other workers & interval between checkpoints
+func runStopped (t *testing.T, dir string, stop int) ([]byte, []byte, Result) {
+
+   cfg := streamingTestConfig(dir)
+   b := NewBinary(cfg)
+   b.Timestamp = "2026-01-01T00:00:00Z"
+
+   if stop >= 0 {
+      err := b.Run(&stopAfterContext{Context: context.Background(), checks: stop})
+      if err != context.Canceled {
+         t.Fatalf("stopped run: got error %v, want %v", err, context.Canceled)
+      }
+
+      cfg.Workers, cfg.CheckpointInterval = 3, 1
+      b = NewBinary(cfg)
+      err = b.LoadCheckpoint(cfg.CheckpointFilename)
+      if err != nil {
+         t.Fatal(err)
+      }
+   }
+
+   err := b.Run(context.Background())
+   if err != nil {
+      t.Fatal(err)
+   }
+
+   kicks, err := ioutil.ReadFile(cfg.KicksFilename)
+   if err != nil {
+      t.Fatal(err)
+   }
+   orbits, err := ioutil.ReadFile(cfg.BoundedBinariesFilename)
+   if err != nil {
+      t.Fatal(err)
+   }
+
+   return kicks, orbits, b.Result()
+
+}
+
+
+// a run stopped in either pass and resumed gives the outputs of a run that was never stopped
+func TestCheckpointResume (t *testing.T) {
+
+   // the same files, whose names are in their headers, for every run
+   dir := t.TempDir()
+   kicks, orbits, result := runStopped(t, dir, -1)
+
+   // 6 batches in each pass: stopped in the first, at its end and in the second
+   for _, stop := range []int{3, 6, 9} {
+      stoppedKicks, stoppedOrbits, stoppedResult := runStopped(t, dir, stop)
+      if !bytes.Equal(stoppedKicks, kicks) {
+         t.Errorf("stopped after %d batches: kicks differ from those of a run that was not stopped", stop)
+      }
+      if !bytes.Equal(stoppedOrbits, orbits) {
+         t.Errorf("stopped after %d batches: orbits differ from those of a run that was not stopped", stop)
+      }
+      if !reflect.DeepEqual(stoppedResult.Grid, result.Grid) || stoppedResult.BoundedFraction != result.BoundedFraction {
+         t.Errorf("stopped after %d batches: grid differs from that of a run that was not stopped", stop)
+      }
+   }
+
+}
+
+
+// a checkpoint cannot be resumed with options that change results
+func TestCheckpointOtherOptions (t *testing.T) {
+
+   dir := t.TempDir()
+   cfg := streamingTestConfig(dir)
+   b := NewBinary(cfg)
+   err := b.Run(&stopAfterContext{Context: context.Background(), checks: 2})
+   if err != context.Canceled {
+      t.Fatalf("stopped run: got error %v, want %v", err, context.Canceled)
+   }
+
+   cfg.Seed++
+   b = NewBinary(cfg)
+   err = b.LoadCheckpoint(cfg.CheckpointFilename)
+   if _, ok := err.(*ConfigError); !ok {
+      t.Errorf("resume with another seed: got error %v, want a ConfigError", err)
+   }
+
+}
@@ -0,0 +1,179 @@
+package orbits
+
+
+// options of a run, as read from the configuration file. Values are in astro units (Msun, Rsun,
+// days, km/s). A Config is never changed by a run: a Binary works on its own copy
+type Config struct {
+   M1 float64 `yaml:"m1"`
+   M2 float64 `yaml:"m2"`
+   Separation float64 `yaml:"separation"`
+   Period float64 `yaml:"period"`
+   
+   MCO float64 `yaml:"compact_object_mass"`
+
+   KickStrengthDistribution string `yaml:"kick_distribution"`
+   KickDirection string `yaml:"kick_direction"`
+
+   ReduceByFallback bool `yaml:"reduce_by_fallback"`
+   FallbackFraction float64 `yaml:"fallback_fraction"`
+
+   SigmaStrength float64 `yaml:"kick_sigma"`
+   MinKickStrength float64 `yaml:"min_kick_value"`
+   MaxKickStrength float64 `yaml:"max_kick_value"`
+
+   MinPhi float64 `yaml:"min_phi"`
+   MaxPhi float64 `yaml:"max_phi"`
+
+   MinTheta float64 `yaml:"min_theta"`
+   MaxTheta float64 `yaml:"max_theta"`
+
+   Sampling string `yaml:"sampling"`
+   QuadratureKickPoints int `yaml:"quadrature_kick_points"`
+   QuadratureThetaPoints int `yaml:"quadrature_theta_points"`
+   QuadraturePhiPoints int `yaml:"quadrature_phi_points"`
+   ProposalKickStrengthDistribution string `yaml:"proposal_kick_distribution"`
+   ProposalKickDirection string `yaml:"proposal_kick_direction"`
+   ProposalSigmaStrength float64 `yaml:"proposal_kick_sigma"`
+   ProposalMinKickStrength float64 `yaml:"proposal_min_kick_value"`
+   ProposalMaxKickStrength float64 `yaml:"proposal_max_kick_value"`
+   ProposalBackwardBias float64 `yaml:"proposal_backward_bias"`
+   
+   Seed uint64 `yaml:"seed"`
+
+   NumberOfCases int `yaml:"number_of_cases"`
+   Workers int `yaml:"workers"`
+   Streaming bool `yaml:"streaming"`
+   StreamBatchSize int `yaml:"stream_batch_size"`
+   CheckpointFilename string `yaml:"checkpoint_filename"`
+   CheckpointInterval int `yaml:"checkpoint_interval"`
+
+   ConvergenceTarget string `yaml:"convergence_target"`
+   TargetRelativeError float64 `yaml:"target_relative_error"`
+   TargetGridError float64 `yaml:"target_grid_error"`
+   BatchSize int `yaml:"batch_size"`
+   MaxNumberOfCases int `yaml:"max_number_of_cases"`
+
+   ReplayKicks bool `yaml:"replay_kicks"`
+   ReplayKicksFilename string `yaml:"replay_kicks_filename"`
+   ReplayKicksFormat string `yaml:"replay_kicks_format"`
+   ReplayConvention string `yaml:"replay_convention"`
+   ReplayColumns map[string]string `yaml:"replay_columns"`
+   
+   LogLevel string `yaml:"log_level"`
+   TerminalPlots bool `yaml:"terminal_plots"`
+
+   StoreKicks bool `yaml:"save_kicks"`
+   StoreOrbits bool `yaml:"save_bounded_orbits"`
+   StoreGrid bool `yaml:"save_grid_of_orbits"`
+   StoreGridMatrix bool `yaml:"save_grid_matrix"`
+
+   StoreSurvivalMaps bool `yaml:"save_survival_maps"`
+   StoreRepresentativeOrbits bool `yaml:"save_representative_orbits"`
+   StoreMultiGrid bool `yaml:"save_multidim_grid"`
+   StoreDatabase bool `yaml:"save_database"`
+
+   KicksFilename string `yaml:"kicks_filename"`
+   BoundedBinariesFilename string `yaml:"bounded_orbits_filename"`
+   GridFilename string `yaml:"grid_of_orbits_filename"`
+   GridMatrixFilename string `yaml:"grid_matrix_filename"`
+   KickThetaMapFilename string `yaml:"kick_theta_map_filename"`
+   ThetaPhiMapFilename string `yaml:"theta_phi_map_filename"`
+   SurvivalBoundaryFilename string `yaml:"survival_boundary_filename"`
+   RepresentativeOrbitsFilename string `yaml:"representative_orbits_filename"`
+   MultiGridFilename string `yaml:"multidim_grid_filename"`
+   MarginalsFilename string `yaml:"marginals_filename"`
+   DatabaseFilename string `yaml:"database_filename"`
+
+   KicksFormat string `yaml:"kicks_format"`
+   BoundedOrbitsFormat string `yaml:"bounded_orbits_format"`
+   GridFormat string `yaml:"grid_of_orbits_format"`
+   RepresentativeOrbitsFormat string `yaml:"representative_orbits_format"`
+   MultiGridFormat string `yaml:"multidim_grid_format"`
+   MarginalsFormat string `yaml:"marginals_format"`
+   KickThetaMapFormat string `yaml:"kick_theta_map_format"`
+   ThetaPhiMapFormat string `yaml:"theta_phi_map_format"`
+   SurvivalBoundaryFormat string `yaml:"survival_boundary_format"`
+
+   SurvivalMapKickBins int `yaml:"survival_map_kick_bins"`
+   SurvivalMapThetaBins int `yaml:"survival_map_theta_bins"`
+   SurvivalMapPhiBins int `yaml:"survival_map_phi_bins"`
+
+   PQuantileMin float64 `yaml:"period_quantile_min"`
+   PQuantileMax float64 `yaml:"period_quantile_max"`
+   EQuantileMin float64 `yaml:"eccentricity_quantile_min"`
+   EQuantileMax float64 `yaml:"eccentricity_quantile_max"`
+   PNum int `yaml:"number_of_periods"`
+   ENum int `yaml:"number_of_eccentricities"`
+   PeriodEdges []float64 `yaml:"period_edges"`
+   EccentricityEdges []float64 `yaml:"eccentricity_edges"`
+   PeriodMin float64 `yaml:"period_min"`
+   PeriodMax float64 `yaml:"period_max"`
+   PeriodScale string `yaml:"period_scale"`
+   EccentricityMin float64 `yaml:"eccentricity_min"`
+   EccentricityMax float64 `yaml:"eccentricity_max"`
+   EccentricityScale string `yaml:"eccentricity_scale"`
+   MinProb float64 `yaml:"minimum_probability_for_grid"`
+   GridNormalization string `yaml:"grid_normalization"`
+
+   GridMethod string `yaml:"grid_method"`
+   KDEBandwidth string `yaml:"kde_bandwidth"`
+   KDEBandwidthLogPeriod float64 `yaml:"kde_bandwidth_log_period"`
+   KDEBandwidthEccentricity float64 `yaml:"kde_bandwidth_eccentricity"`
+   AdaptiveMaxProbability float64 `yaml:"adaptive_max_probability"`
+   AdaptiveMaxSamples int `yaml:"adaptive_max_samples"`
+   AdaptiveMaxDepth int `yaml:"adaptive_max_depth"`
+
+   GridAxes []GridAxis `yaml:"grid_axes"`
+
+   NumberOfRepresentativeOrbits int `yaml:"number_of_representative_orbits"`
+   RepresentativeUseVsys bool `yaml:"representative_use_vsys"`
+   RepresentativeMaxIterations int `yaml:"representative_max_iterations"`
+
+   ConfidenceLevel float64 `yaml:"confidence_level"`
+   GridIntervalMethod string `yaml:"grid_interval_method"`
+   BootstrapSamples int `yaml:"bootstrap_samples"`
+}
+
+
+// options that might be missing in a configuration file
+func DefaultConfig () Config {
+
+   return Config{
+      ConfidenceLevel: 0.95,
+      GridIntervalMethod: "multinomial",
+      BootstrapSamples: 1000,
+      KDEBandwidth: "scott",
+      AdaptiveMaxDepth: 8,
+      RepresentativeMaxIterations: 100,
+      PeriodScale: "log",
+      EccentricityScale: "linear",
+      GridNormalization: "bounded",
+   }
+
+}
+
+
+// copy of a configuration that shares no slice nor map with it
+func (c Config) clone () Config {
+
+   // empty (not nil) slices are kept empty, they are written as such in the metadata
+   if c.PeriodEdges != nil {
+      c.PeriodEdges = append(make([]float64, 0, len(c.PeriodEdges)), c.PeriodEdges...)
+   }
+   if c.EccentricityEdges != nil {
+      c.EccentricityEdges = append(make([]float64, 0, len(c.EccentricityEdges)), c.EccentricityEdges...)
+   }
+   if c.GridAxes != nil {
+      c.GridAxes = append(make([]GridAxis, 0, len(c.GridAxes)), c.GridAxes...)
+   }
+   if c.ReplayColumns != nil {
+      columns := make(map[string]string, len(c.ReplayColumns))
+      for quantity, name := range c.ReplayColumns {
+         columns[quantity] = name
+      }
+      c.ReplayColumns = columns
+   }
+
+   return c
+
+}
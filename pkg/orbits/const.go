@@ -1,20 +1,27 @@
 package orbits
 
+import (
+   "github.com/asimazbunzel/go-orbits/pkg/units"
+)
+
+// constants in CGS, kept as plain numbers for the equations of the orbits. Typed quantities &
+// conversions are in package units
 const (
 
    // gravitational constant
-   StandardCgrav = 6.67430e-8
+   StandardCgrav = units.G
 
    // seconds in a year
-   SecYer = 24.0 * 60.0 * 60.0 * 365.25
+   SecYer = float64(units.Year)
 
+   // speed of light
+   Clight = units.C
 
-   // km to cm
-   km2cm = 1e5
 
-   mu_sun = 1.3271244e26
+   // km to cm
+   km2cm = float64(units.Kilometer)
 
-   Msun = mu_sun / StandardCgrav
-   Rsun = 6.957e10
+   Msun = float64(units.SolarMass)
+   Rsun = float64(units.SolarRadius)
 
 )
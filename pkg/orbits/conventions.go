@@ -0,0 +1,154 @@
+package orbits
+
+import (
+   "errors"
+   "math"
+   "sort"
+   "strings"
+)
+
+
+// The frame of OrbitsAfterKicks (Kalogera 1996) has its x axis along the separation, from the
+// exploding star to its companion, y along the pre-SN velocity of the exploding star relative to
+// its companion and z along the orbital angular momentum. A kick of strength w has components
+//
+//    wx = w sin(theta) cos(phi),   wy = w cos(theta),   wz = w sin(phi) sin(theta)
+//
+// so theta is measured from the pre-SN velocity and phi from the separation, towards z. Other
+// codes use other angles, converted to this frame through the direction of the kick
+
+
+// convention of the kicks of a code: names of the columns of (w, theta, phi) in its tables, rows
+// of its text & csv tables above the names of the columns, and direction of a kick in the frame
+// of OrbitsAfterKicks from its angles (and back)
+type KickConvention struct {
+   Name string
+   Columns map[string]string
+   HeaderRows int
+   direction func (theta float64, phi float64) [3]float64
+   angles func (d [3]float64) (float64, float64)
+}
+
+
+// conventions of kicks by name
+var kickConventions = map[string]KickConvention{
+
+   // this code, and POSYDON: both follow Kalogera (1996), with the natal kick array of POSYDON
+   // as (w, phi, theta)
+   "go-orbits": {
+      Name: "go-orbits",
+      Columns: map[string]string{"w": "w", "theta": "theta", "phi": "phi", "weight": "weight"},
+      direction: kickDirection,
+      angles: kickAngles,
+   },
+   "posydon": {
+      Name: "posydon",
+      Columns: map[string]string{"w": "natal_kick_array_0", "theta": "natal_kick_array_2", "phi": "natal_kick_array_1", "weight": "weight"},
+      direction: kickDirection,
+      angles: kickAngles,
+   },
+
+   // COMPAS: theta is the angle out of the orbital plane, in [-pi/2, pi/2], and phi the angle in
+   // the plane, counter-clockwise from the separation vector pointing away from the companion
+   // (-x), so the kick is w (cos(theta) cos(phi), cos(theta) sin(phi), sin(theta)) in (-x, y, z).
+   // Its CSV, TSV & TXT logs have a row of data types and one of units above the column names
+   "compas": {
+      Name: "compas",
+      Columns: map[string]string{"w": "Applied_Kick_Magnitude(SN)", "theta": "SN_Kick_Theta(SN)", "phi": "SN_Kick_Phi(SN)", "weight": "weight"},
+      HeaderRows: 2,
+      direction: func (theta float64, phi float64) [3]float64 {
+         return [3]float64{-math.Cos(theta) * math.Cos(phi), math.Cos(theta) * math.Sin(phi), math.Sin(theta)}
+      },
+      angles: func (d [3]float64) (float64, float64) {
+         return math.Asin(clampUnit(d[2])), wrapAngle(math.Atan2(d[1], -d[0]))
+      },
+   },
+
+   // BSE & MOBSE (Hurley et al. 2002, kick.f): theta is measured from the separation and phi
+   // around it, from the direction opposite to the orbital velocity, so the kick is
+   // w (cos(theta), sin(theta) cos(phi), sin(theta) sin(phi)) in (x, -y, -z)
+   "bse": {
+      Name: "bse",
+      Columns: map[string]string{"w": "vk", "theta": "theta", "phi": "phi", "weight": "weight"},
+      direction: func (theta float64, phi float64) [3]float64 {
+         return [3]float64{math.Cos(theta), -math.Sin(theta) * math.Cos(phi), -math.Sin(theta) * math.Sin(phi)}
+      },
+      angles: func (d [3]float64) (float64, float64) {
+         return math.Acos(clampUnit(d[0])), wrapAngle(math.Atan2(-d[2], -d[1]))
+      },
+   },
+
+}
+
+
+// convention of kicks by name (go-orbits if empty)
+func KickConventionByName (name string) (KickConvention, error) {
+
+   if name == "" {
+      name = "go-orbits"
+   }
+   c, ok := kickConventions[strings.ToLower(name)]
+   if !ok {
+      var names []string
+      for n, _ := range kickConventions {
+         names = append(names, n)
+      }
+      sort.Strings(names)
+      return c, errors.New("unknown kick convention: " + name + ", options are: " + strings.Join(names, ", "))
+   }
+
+   return c, nil
+
+}
+
+
+// angles of a kick in the frame of OrbitsAfterKicks from those of the convention
+func (c KickConvention) ToFrame (theta float64, phi float64) (float64, float64) {
+
+   return kickAngles(c.direction(theta, phi))
+
+}
+
+
+// angles of a kick in the convention from those of the frame of OrbitsAfterKicks
+func (c KickConvention) FromFrame (theta float64, phi float64) (float64, float64) {
+
+   return c.angles(kickDirection(theta, phi))
+
+}
+
+
+// unit vector of a kick in the frame of OrbitsAfterKicks
+func kickDirection (theta float64, phi float64) [3]float64 {
+
+   return [3]float64{math.Sin(theta) * math.Cos(phi), math.Cos(theta), math.Sin(theta) * math.Sin(phi)}
+
+}
+
+
+// angles of a unit vector in the frame of OrbitsAfterKicks, theta in [0, pi] & phi in [0, 2pi)
+func kickAngles (d [3]float64) (float64, float64) {
+
+   return math.Acos(clampUnit(d[1])), wrapAngle(math.Atan2(d[2], d[0]))
+
+}
+
+
+// keep a cosine (or sine) within [-1, 1] against rounding errors
+func clampUnit (x float64) float64 {
+
+   return math.Max(-1.0, math.Min(1.0, x))
+
+}
+
+
+// angle in [0, 2pi)
+func wrapAngle (phi float64) float64 {
+
+   if phi < 0 {
+      phi += 2.0 * math.Pi
+   }
+
+   return phi
+
+}
@@ -0,0 +1,125 @@
+package orbits
+
+import (
+   "math"
+   "os"
+   "path/filepath"
+   "testing"
+)
+
+
+// whether two angles are the same, within tol, modulo 2pi
+func sameAngle (x float64, y float64, tol float64) bool {
+
+   d := math.Mod(math.Abs(x - y), 2.0 * math.Pi)
+
+   return d < tol || 2.0 * math.Pi - d < tol
+
+}
+
+
+// angles of the frame of OrbitsAfterKicks survive going to every convention and back
+func TestConventionsRoundTrip (t *testing.T) {
+
+   for name, c := range kickConventions {
+      // away from the poles (theta = 0 or pi), where phi is undefined
+      for theta := 0.05; theta < math.Pi; theta += 0.3 {
+         for phi := 0.0; phi < 2.0 * math.Pi; phi += 0.35 {
+            thetaC, phiC := c.FromFrame(theta, phi)
+            gotTheta, gotPhi := c.ToFrame(thetaC, phiC)
+            if math.Abs(gotTheta - theta) > 1e-12 || !sameAngle(gotPhi, phi, 1e-12) {
+               t.Errorf("%s: ToFrame(FromFrame(%f, %f)) = (%f, %f)", name, theta, phi, gotTheta, gotPhi)
+            }
+         }
+      }
+   }
+
+}
+
+
+// kicks along known directions (unit vectors in the frame of OrbitsAfterKicks: x along the
+// separation, y along the pre-SN velocity and z along the orbital angular momentum), with their
+// angles in each convention and in that frame
+func TestConventionsKnownVectors (t *testing.T) {
+
+   cases := []struct {
+      convention string
+      theta, phi float64
+      direction [3]float64
+      frameTheta, framePhi float64
+   }{
+      // go-orbits: theta from the pre-SN velocity (+y) and phi from the separation (+x) towards
+      // the orbital angular momentum (+z)
+      {"go-orbits", 0, 0, [3]float64{0, 1, 0}, 0, 0},
+      {"go-orbits", 0.5 * math.Pi, 0, [3]float64{1, 0, 0}, 0.5 * math.Pi, 0},
+      {"go-orbits", 0.5 * math.Pi, 0.5 * math.Pi, [3]float64{0, 0, 1}, 0.5 * math.Pi, 0.5 * math.Pi},
+      {"posydon", 0.3, 1.2, [3]float64{math.Sin(0.3) * math.Cos(1.2), math.Cos(0.3), math.Sin(0.3) * math.Sin(1.2)}, 0.3, 1.2},
+      // COMPAS: out of the plane (+z), and in the plane away from the companion (-x) or along
+      // the orbital velocity (+y)
+      {"compas", 0.5 * math.Pi, 0, [3]float64{0, 0, 1}, 0.5 * math.Pi, 0.5 * math.Pi},
+      {"compas", 0, 0, [3]float64{-1, 0, 0}, 0.5 * math.Pi, math.Pi},
+      {"compas", 0, 0.5 * math.Pi, [3]float64{0, 1, 0}, 0, 0},
+      // BSE: along the separation (+x), opposite to the orbital velocity (-y) and to the
+      // orbital angular momentum (-z)
+      {"bse", 0, 0, [3]float64{1, 0, 0}, 0.5 * math.Pi, 0},
+      {"bse", 0.5 * math.Pi, 0, [3]float64{0, -1, 0}, math.Pi, 0},
+      {"bse", 0.5 * math.Pi, 0.5 * math.Pi, [3]float64{0, 0, -1}, 0.5 * math.Pi, 1.5 * math.Pi},
+   }
+
+   for _, k := range cases {
+      c, err := KickConventionByName(k.convention)
+      if err != nil {
+         t.Fatal(err)
+      }
+      d := c.direction(k.theta, k.phi)
+      for i, _ := range d {
+         if math.Abs(d[i] - k.direction[i]) > 1e-12 {
+            t.Errorf("%s (%f, %f): got direction %v, want %v", k.convention, k.theta, k.phi, d, k.direction)
+            break
+         }
+      }
+      theta, phi := c.ToFrame(k.theta, k.phi)
+      // phi is undefined along y
+      poles := math.Abs(math.Sin(k.frameTheta)) < 1e-12
+      if math.Abs(theta - k.frameTheta) > 1e-12 || (!poles && !sameAngle(phi, k.framePhi, 1e-12)) {
+         t.Errorf("%s (%f, %f): got (%f, %f) in the frame, want (%f, %f)", k.convention, k.theta, k.phi, theta, phi, k.frameTheta, k.framePhi)
+      }
+   }
+
+}
+
+
+// COMPAS logs have rows of data types & units above the column names
+func TestLoadKicksCompasLog (t *testing.T) {
+
+   dir := t.TempDir()
+   log := "INT,FLOAT,FLOAT,FLOAT\n" +
+      "-,km s^-1,-,-\n" +
+      "SEED,Applied_Kick_Magnitude(SN),SN_Kick_Theta(SN),SN_Kick_Phi(SN)\n" +
+      "1,265.5,1.5707963267948966,0\n" +
+      "2,100,0,0\n"
+   filename := filepath.Join(dir, "BSE_Supernovae.csv")
+   err := os.WriteFile(filename, []byte(log), 0644)
+   if err != nil {
+      t.Fatal(err)
+   }
+
+   b := NewBinary(Config{ReplayConvention: "compas", LogLevel: "none"})
+   err = b.LoadKicks(filename, "")
+   if err != nil {
+      t.Fatal(err)
+   }
+   if len(b.W) != 2 || b.W[0] != 265.5 || b.W[1] != 100 {
+      t.Fatalf("kick strengths: got %v, want [265.5 100]", b.W)
+   }
+   if math.Abs(b.Theta[0] - 0.5 * math.Pi) > 1e-12 || math.Abs(b.Phi[0] - 0.5 * math.Pi) > 1e-12 {
+      t.Errorf("kick along +z: got theta=%f, phi=%f, want pi/2, pi/2", b.Theta[0], b.Phi[0])
+   }
+
+   // HDF5 logs are refused
+   err = b.LoadKicks(filepath.Join(dir, "COMPAS_Output.h5"), "")
+   if _, ok := err.(*ConfigError); !ok {
+      t.Errorf("COMPAS HDF5 log: got error %v, want a ConfigError", err)
+   }
+
+}
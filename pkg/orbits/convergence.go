@@ -0,0 +1,136 @@
+package orbits
+
+import (
+   "fmt"
+   "math"
+   "strconv"
+
+   "github.com/asimazbunzel/go-orbits/pkg/io"
+   "github.com/asimazbunzel/go-orbits/pkg/units"
+
+   "gonum.org/v1/gonum/floats"
+)
+
+
+// draw kicks in batches of BatchSize and solve their orbits until the precision asked in the
+// config is reached or MaxNumberOfCases kicks were drawn, which gives a ConvergenceError (with
+// every result set). Kicks are drawn from the same random streams as in ComputeKicks, so
+// results only depend on the seed and the size of the batches. The binary is converted to CGS
+// units if it is not already
+func (b *Binary) KicksUntilConverged () error {
+
+   if b.LogLevel != "none" {
+      io.LogInfo("ORBITS - convergence.go - KicksUntilConverged", "computing momentum kicks until convergence on: " + b.ConvergenceTarget)
+   }
+
+   if b.BatchSize <= 0 || b.MaxNumberOfCases <= 0 {
+      return &ConfigError{Option: "batch_size", Message: "`batch_size` and `max_number_of_cases` must be greater than 0"}
+   }
+
+   if b.Sampling == "quadrature" {
+      return &ConfigError{Option: "convergence_target", Message: "convergence control needs random kicks, not a quadrature grid"}
+   }
+
+   target := b.TargetRelativeError
+   if b.ConvergenceTarget == "grid" {
+      target = b.TargetGridError
+      err := b.checkGridOptions()
+      if err != nil {
+         return err
+      }
+   } else if b.ConvergenceTarget != "survival" {
+      return &ConfigError{Option: "convergence_target", Message: "unknown value \"" + b.ConvergenceTarget + "\", options are: none, survival, grid"}
+   }
+
+   // kicks (and every result) of previous calls are replaced
+   b.ConvertoCGS()
+   b.Reset()
+
+   for len(b.W) < b.MaxNumberOfCases {
+
+      first := len(b.W)
+      n := b.BatchSize
+      if first + n > b.MaxNumberOfCases {
+         n = b.MaxNumberOfCases - first
+      }
+
+      // kicks are drawn in km/s, but orbits are solved in CGS
+      err := b.drawKicks(n)
+      if err != nil {
+         return err
+      }
+      floats.Scale(float64(units.KilometerPerSecond), b.W[first:])
+      b.NumberOfCases = len(b.W)
+
+      b.solveOrbits(first, len(b.W))
+
+      b.AchievedError, err = b.convergenceError()
+      if err != nil {
+         return err
+      }
+
+      if b.LogLevel == "debug" {
+         msg := "number of kicks: " + strconv.Itoa(b.NumberOfCases) + ", error: " + strconv.FormatFloat(b.AchievedError, 'E', 3, 64)
+         io.LogDebug("ORBITS - convergence.go - KicksUntilConverged", msg)
+      }
+
+      if b.AchievedError <= target {
+         break
+      }
+   }
+
+   b.boundedFractionIntervals()
+
+   if b.LogLevel == "info" || b.LogLevel == "debug" {
+      b.printSummary()
+      fmt.Println("Convergence of momentum kicks:")
+      fmt.Printf("target: %s\n", b.ConvergenceTarget)
+      fmt.Printf("error achieved: %.3E (requested %.3E)\n", b.AchievedError, target)
+      fmt.Printf("final number of kicks: %d\n\n", b.NumberOfCases)
+   }
+
+   if b.AchievedError > target {
+      return &ConvergenceError{Target: b.ConvergenceTarget, AchievedError: b.AchievedError, TargetError: target, NumberOfCases: b.NumberOfCases}
+   }
+
+   return nil
+
+}
+
+
+// error used to decide on convergence: relative error of the bounded fraction or maximum
+// standard error on the cells of the grid of orbits above the minimum probability
+func (b *Binary) convergenceError () (float64, error) {
+
+   if len(b.IndexBounded) == 0 {
+      return math.Inf(1), nil
+   }
+
+   if b.ConvergenceTarget == "survival" {
+      return b.BoundedFractionError() / b.BoundedFraction(), nil
+   }
+
+   pBorders, eBorders, err := b.gridBorders()
+   if err != nil {
+      return 0, err
+   }
+   probabilities, squares := b.histogramOfOrbits(pBorders, eBorders)
+
+   total := floats.Sum(b.WeightBounded)
+   qBounded := floats.Dot(b.WeightBounded, b.WeightBounded) / math.Pow(total,2.0)
+
+   maxError := math.Inf(1)
+   for i, _ := range probabilities {
+      for j, p := range probabilities[i] {
+         if p > b.MinProb {
+            e := math.Sqrt(math.Pow(1.0-p,2.0) * squares[i][j] + math.Pow(p,2.0) * (qBounded - squares[i][j]))
+            if math.IsInf(maxError, 1) || e > maxError {
+               maxError = e
+            }
+         }
+      }
+   }
+
+   return maxError, nil
+
+}
@@ -0,0 +1,63 @@
+package orbits
+
+import (
+   "context"
+   "testing"
+)
+
+
+// reaching the maximum number of cases before the target is a ConvergenceError, with results
+func TestKicksUntilConvergedMaximum (t *testing.T) {
+
+   cfg := testConfig(0)
+   cfg.ConvergenceTarget = "survival"
+   cfg.TargetRelativeError = 1e-6
+   cfg.BatchSize, cfg.MaxNumberOfCases = 1000, 3000
+
+   result, err := Run(context.Background(), cfg)
+   e, ok := err.(*ConvergenceError)
+   if !ok {
+      t.Fatalf("got error %v, want a ConvergenceError", err)
+   }
+   if e.NumberOfCases != 3000 || e.AchievedError <= e.TargetError {
+      t.Errorf("got %d kicks with error %e, want 3000 kicks above %e", e.NumberOfCases, e.AchievedError, e.TargetError)
+   }
+   if len(result.Kicks) != 3000 || len(result.Grid) == 0 {
+      t.Errorf("got %d kicks and %d cells, want every result of the run", len(result.Kicks), len(result.Grid))
+   }
+
+}
+
+
+// batches are drawn until the error is below the target, and not one batch more
+func TestKicksUntilConvergedTarget (t *testing.T) {
+
+   targets := map[string]float64{"survival": 0.01, "grid": 0.003}
+   for target, value := range targets {
+      cfg := testConfig(0)
+      cfg.ConvergenceTarget = target
+      cfg.TargetRelativeError, cfg.TargetGridError = value, value
+      cfg.BatchSize, cfg.MaxNumberOfCases = 1000, 1000000
+
+      b := NewBinary(cfg)
+      err := b.KicksUntilConverged()
+      if err != nil {
+         t.Fatalf("%s: %v", target, err)
+      }
+      if b.AchievedError > value {
+         t.Errorf("%s: got error %e, want it below %e", target, b.AchievedError, value)
+      }
+      if b.NumberOfCases % cfg.BatchSize != 0 || b.NumberOfCases <= cfg.BatchSize || b.NumberOfCases >= cfg.MaxNumberOfCases {
+         t.Fatalf("%s: got %d kicks, want more than one whole batch below the maximum", target, b.NumberOfCases)
+      }
+
+      // the error was above the target one batch before
+      previous := NewBinary(cfg)
+      previous.MaxNumberOfCases = b.NumberOfCases - cfg.BatchSize
+      err = previous.KicksUntilConverged()
+      if _, ok := err.(*ConvergenceError); !ok {
+         t.Errorf("%s: with %d kicks, got error %v, want a ConvergenceError", target, previous.MaxNumberOfCases, err)
+      }
+   }
+
+}
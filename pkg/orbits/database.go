@@ -0,0 +1,459 @@
+package orbits
+
+import (
+   "database/sql"
+   "errors"
+   "math"
+   "regexp"
+   "strconv"
+   "strings"
+
+   "github.com/asimazbunzel/go-orbits/pkg/io"
+
+   // pure-Go driver of SQLite, registered as "sqlite"
+   _ "modernc.org/sqlite"
+)
+
+
+// summary of a run as columns of the runs table, filled from its provenance keywords
+var runColumns = []struct {
+   Name string
+   Type string
+}{
+   {"program", "TEXT"},
+   {"version", "TEXT"},
+   {"revision", "TEXT"},
+   {"timestamp", "TEXT"},
+   {"config_file", "TEXT"},
+   {"config_hash", "TEXT"},
+   {"number_of_kicks", "INTEGER"},
+   {"number_of_bounded", "INTEGER"},
+   {"bounded_fraction", "REAL"},
+   {"bounded_fraction_wilson_lower", "REAL"},
+   {"bounded_fraction_wilson_upper", "REAL"},
+   {"bounded_fraction_clopper_pearson_lower", "REAL"},
+   {"bounded_fraction_clopper_pearson_upper", "REAL"},
+   {"effective_number_of_kicks", "REAL"},
+   {"achieved_error", "REAL"},
+   {"probability_outside_grid", "REAL"},
+   {"probability_below_threshold", "REAL"},
+}
+
+
+// store a run in a SQLite database, created if needed: its summary goes to the runs table, and
+// its options (config), kicks, outcomes of bounded binaries and grid to tables with the id of
+// the run (run_id). Must be called in astro units
+func (b *Binary) SaveDatabase (filename string) error {
+
+   if b.LogLevel != "none" {
+      io.LogInfo("ORBITS - database.go - SaveDatabase", "saving run to database " + filename)
+   }
+
+   id, err := b.saveDatabase(filename)
+   if err != nil {
+      return &IOError{Filename: filename, Op: "save run to database", Err: err}
+   }
+
+   if b.LogLevel != "none" {
+      io.LogInfo("ORBITS - database.go - SaveDatabase", "run saved with id " + strconv.FormatInt(id, 10))
+   }
+
+   return nil
+
+}
+
+
+// store a run in a single transaction, returning its id
+func (b *Binary) saveDatabase (filename string) (int64, error) {
+
+   db, err := sql.Open("sqlite", filename)
+   if err != nil {
+      return 0, err
+   }
+
+   // remember to close the database
+   defer db.Close()
+
+   tx, err := db.Begin()
+   if err != nil {
+      return 0, err
+   }
+   // no effect once committed
+   defer tx.Rollback()
+
+   // summary of the run
+   columns := [][2]string{{"id", "INTEGER PRIMARY KEY AUTOINCREMENT"}}
+   names := make([]string, len(runColumns))
+   for k, c := range runColumns {
+      columns = append(columns, [2]string{c.Name, c.Type})
+      names[k] = c.Name
+   }
+   err = createTable(tx, "runs", columns)
+   if err != nil {
+      return 0, err
+   }
+
+   summary := make(map[string]string)
+   for _, kw := range b.ProvenanceKeywords() {
+      summary[kw.Name] = kw.Value
+   }
+   summary["probability_outside_grid"] = strconv.FormatFloat(b.ProbabilityOutsideGrid, 'g', -1, 64)
+   summary["probability_below_threshold"] = strconv.FormatFloat(b.ProbabilityBelowThreshold, 'g', -1, 64)
+   values := make([]interface{}, len(runColumns))
+   for k, c := range runColumns {
+      value, ok := summary[c.Name]
+      if !ok {
+         continue
+      }
+      values[k] = value
+      // numbers are stored as such, and those that are not finite (e.g. the error of a run that
+      // did not converge) as NULL
+      if x, err := strconv.ParseFloat(value, 64); err == nil && c.Type != "TEXT" {
+         values[k] = sqlNumber(x)
+      }
+   }
+   result, err := tx.Exec("INSERT INTO runs (" + strings.Join(names, ", ") + ") VALUES (" + placeholders(len(names)) + ")", values...)
+   if err != nil {
+      return 0, err
+   }
+   id, err := result.LastInsertId()
+   if err != nil {
+      return 0, err
+   }
+
+   // options of the run, one per row
+   err = createTable(tx, "config", [][2]string{{"run_id", "INTEGER REFERENCES runs(id)"}, {"name", "TEXT"}, {"value", "TEXT"}})
+   if err != nil {
+      return 0, err
+   }
+   stmt, err := tx.Prepare("INSERT INTO config (run_id, name, value) VALUES (?, ?, ?)")
+   if err != nil {
+      return 0, err
+   }
+   for _, kw := range b.ConfigKeywords() {
+      _, err = stmt.Exec(id, kw.Name, kw.Value)
+      if err != nil {
+         stmt.Close()
+         return 0, err
+      }
+   }
+   stmt.Close()
+
+   // kicks, outcomes of bounded binaries (by the id of their kick) and grid
+   kickID := make([]float64, len(b.IndexBounded))
+   for k, kb := range b.IndexBounded {
+      kickID[k] = float64(kb)
+   }
+   outcomes := Table{Columns: []Column{{Name: "kick_id", Values: kickID, Integer: true}}}
+   for _, quantity := range gridQuantities {
+      outcomes.Columns = append(outcomes.Columns, Column{Name: quantity, Values: b.boundedQuantity(quantity)})
+   }
+   outcomes.Columns = append(outcomes.Columns, Column{Name: "weight", Values: b.WeightBounded})
+
+   tables := map[string]Table{"kicks": b.KicksTable(), "outcomes": outcomes, "grid": b.GridTable()}
+   for _, name := range []string{"kicks", "outcomes", "grid"} {
+      err = insertTable(tx, name, id, tables[name])
+      if err != nil {
+         return 0, err
+      }
+   }
+
+   return id, tx.Commit()
+
+}
+
+
+// create (if needed) a table with the columns of t and a run_id, and insert its rows
+func insertTable (tx *sql.Tx, name string, id int64, t Table) error {
+
+   columns := [][2]string{{"run_id", "INTEGER REFERENCES runs(id)"}}
+   names := []string{"run_id"}
+   for _, c := range t.Columns {
+      kind := "REAL"
+      if c.Integer {
+         kind = "INTEGER"
+      }
+      columns = append(columns, [2]string{c.Name, kind})
+      names = append(names, "\"" + c.Name + "\"")
+   }
+   err := createTable(tx, name, columns)
+   if err != nil {
+      return err
+   }
+
+   stmt, err := tx.Prepare("INSERT INTO " + name + " (" + strings.Join(names, ", ") + ") VALUES (" + placeholders(len(names)) + ")")
+   if err != nil {
+      return err
+   }
+
+   // remember to close the statement
+   defer stmt.Close()
+
+   values := make([]interface{}, len(names))
+   values[0] = id
+   for k := 0; k < t.Rows(); k++ {
+      for j, c := range t.Columns {
+         if c.Integer {
+            values[j+1] = int64(c.Values[k])
+         } else {
+            values[j+1] = sqlNumber(c.Values[k])
+         }
+      }
+      _, err = stmt.Exec(values...)
+      if err != nil {
+         return err
+      }
+   }
+
+   return nil
+
+}
+
+
+// create a table with columns (name & type) if it does not exist, or add to it the columns it
+// lacks, e.g. in a database made by a previous version or with other grid axes
+func createTable (tx *sql.Tx, name string, columns [][2]string) error {
+
+   definitions := make([]string, len(columns))
+   for k, c := range columns {
+      definitions[k] = "\"" + c[0] + "\" " + c[1]
+   }
+   _, err := tx.Exec("CREATE TABLE IF NOT EXISTS " + name + " (" + strings.Join(definitions, ", ") + ")")
+   if err != nil {
+      return err
+   }
+
+   // columns of the table as it is: cid, name, type, notnull, dflt_value, pk
+   rows, err := tx.Query("PRAGMA table_info(" + name + ")")
+   if err != nil {
+      return err
+   }
+   existing := make(map[string]bool)
+   for rows.Next() {
+      var cid, notNull, pk int
+      var column, kind string
+      var value sql.NullString
+      err = rows.Scan(&cid, &column, &kind, &notNull, &value, &pk)
+      if err != nil {
+         rows.Close()
+         return err
+      }
+      existing[column] = true
+   }
+   rows.Close()
+   if err = rows.Err(); err != nil {
+      return err
+   }
+
+   for k, c := range columns {
+      if existing[c[0]] {
+         continue
+      }
+      _, err = tx.Exec("ALTER TABLE " + name + " ADD COLUMN " + definitions[k])
+      if err != nil {
+         return err
+      }
+   }
+
+   return nil
+
+}
+
+
+// value of a REAL column: x, or NULL if not finite
+func sqlNumber (x float64) interface{} {
+
+   if math.IsNaN(x) || math.IsInf(x, 0) {
+      return nil
+   }
+
+   return x
+
+}
+
+
+// n placeholders of values of a statement
+func placeholders (n int) string {
+
+   return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+
+}
+
+
+// run stored in a database: its id, summary and every option of its configuration
+type RunRecord struct {
+   ID int64
+   Timestamp string
+   ConfigFile string
+   NumberOfKicks int64
+   BoundedFraction float64
+   Options map[string]string
+}
+
+
+// condition on runs such as "kick_sigma < 100", on an option of the configuration or on a column
+// of the runs table
+type RunFilter struct {
+   Name string
+   Operator string
+   Value string
+}
+
+
+var runFilterRegexp = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)\s*(<=|>=|!=|==|=|<|>)\s*(.*?)\s*$`)
+
+
+// parse and validate a filter: its name must be an option of the configuration or a column of
+// the runs table, and strings can only be compared with = or !=
+func ParseRunFilter (text string) (RunFilter, error) {
+
+   m := runFilterRegexp.FindStringSubmatch(text)
+   if m == nil || m[3] == "" {
+      return RunFilter{}, errors.New("invalid filter: " + text + ", expected <name> <operator> <value> with operator one of <, <=, >, >=, =, !=")
+   }
+   f := RunFilter{Name: m[1], Operator: m[2], Value: strings.Trim(m[3], `"'`)}
+   if f.Operator == "==" {
+      f.Operator = "="
+   }
+   // the fraction of bounded binaries is also known as survival fraction
+   if f.Name == "survival_fraction" {
+      f.Name = "bounded_fraction"
+   }
+
+   if !f.OnRuns() && !isConfigOption(f.Name) {
+      return f, errors.New("unknown field in filter: " + f.Name)
+   }
+   if _, err := strconv.ParseFloat(f.Value, 64); err != nil && f.Operator != "=" && f.Operator != "!=" {
+      return f, errors.New("only numbers can be compared with " + f.Operator + " in filter: " + text)
+   }
+
+   return f, nil
+
+}
+
+
+// whether a filter is on a column of the runs table (otherwise it is on an option)
+func (f RunFilter) OnRuns () bool {
+
+   if f.Name == "id" {
+      return true
+   }
+   for _, c := range runColumns {
+      if c.Name == f.Name {
+         return true
+      }
+   }
+
+   return false
+
+}
+
+
+// whether name is an option of the configuration file
+func isConfigOption (name string) bool {
+
+   var b Binary
+   for _, kw := range b.ConfigKeywords() {
+      if kw.Name == name {
+         return true
+      }
+   }
+
+   return false
+
+}
+
+
+// condition of a filter in SQL, with its arguments. Names are validated before, values are
+// always arguments
+func (f RunFilter) sql () (string, []interface{}) {
+
+   var value interface{} = f.Value
+   numeric := false
+   if x, err := strconv.ParseFloat(f.Value, 64); err == nil {
+      value = x
+      numeric = true
+   }
+
+   if f.OnRuns() {
+      return "runs." + f.Name + " " + f.Operator + " ?", []interface{}{value}
+   }
+
+   field := "config.value"
+   if numeric {
+      field = "CAST(config.value AS REAL)"
+   }
+
+   return "EXISTS (SELECT 1 FROM config WHERE config.run_id = runs.id AND config.name = ? AND " + field + " " + f.Operator + " ?)", []interface{}{f.Name, value}
+
+}
+
+
+// runs of a database that match every filter, ordered by id
+func QueryRuns (filename string, filters []RunFilter) ([]RunRecord, error) {
+
+   db, err := sql.Open("sqlite", filename)
+   if err != nil {
+      return nil, err
+   }
+
+   // remember to close the database
+   defer db.Close()
+
+   query := "SELECT id, timestamp, config_file, number_of_kicks, bounded_fraction FROM runs"
+   var conditions []string
+   var args []interface{}
+   for _, f := range filters {
+      condition, fArgs := f.sql()
+      conditions = append(conditions, condition)
+      args = append(args, fArgs...)
+   }
+   if len(conditions) > 0 {
+      query += " WHERE " + strings.Join(conditions, " AND ")
+   }
+
+   rows, err := db.Query(query + " ORDER BY id", args...)
+   if err != nil {
+      return nil, err
+   }
+   var runs []RunRecord
+   for rows.Next() {
+      var r RunRecord
+      var timestamp, configFile sql.NullString
+      var kicks sql.NullInt64
+      var fraction sql.NullFloat64
+      err = rows.Scan(&r.ID, &timestamp, &configFile, &kicks, &fraction)
+      if err != nil {
+         rows.Close()
+         return nil, err
+      }
+      r.Timestamp, r.ConfigFile, r.NumberOfKicks, r.BoundedFraction = timestamp.String, configFile.String, kicks.Int64, fraction.Float64
+      runs = append(runs, r)
+   }
+   rows.Close()
+   if err = rows.Err(); err != nil {
+      return nil, err
+   }
+
+   // options of each run
+   for k, _ := range runs {
+      runs[k].Options = make(map[string]string)
+      rows, err := db.Query("SELECT name, value FROM config WHERE run_id = ?", runs[k].ID)
+      if err != nil {
+         return nil, err
+      }
+      for rows.Next() {
+         var name, value string
+         err = rows.Scan(&name, &value)
+         if err != nil {
+            rows.Close()
+            return nil, err
+         }
+         runs[k].Options[name] = value
+      }
+      rows.Close()
+   }
+
+   return runs, nil
+
+}
@@ -0,0 +1,132 @@
+package orbits
+
+import (
+   "context"
+   "database/sql"
+   "math"
+   "path/filepath"
+   "reflect"
+   "testing"
+)
+
+
+// binary of a small run, ready to be saved
+func databaseTestBinary (t *testing.T, sigma float64) Binary {
+
+   cfg := testConfig(2000)
+   cfg.SigmaStrength = sigma
+   b := NewBinary(cfg)
+   err := b.Run(context.Background())
+   if err != nil {
+      t.Fatal(err)
+   }
+
+   return b
+
+}
+
+
+// runs saved to a database are found by filters on their options & summary
+func TestSaveQueryRuns (t *testing.T) {
+
+   filename := filepath.Join(t.TempDir(), "orbits.db")
+   for _, sigma := range []float64{50, 265} {
+      b := databaseTestBinary(t, sigma)
+      err := b.SaveDatabase(filename)
+      if err != nil {
+         t.Fatal(err)
+      }
+   }
+
+   runs, err := QueryRuns(filename, nil)
+   if err != nil {
+      t.Fatal(err)
+   }
+   if len(runs) != 2 || runs[0].NumberOfKicks != 2000 || runs[1].Options["kick_sigma"] != "265" {
+      t.Fatalf("got runs %+v, want 2 runs of 2000 kicks", runs)
+   }
+
+   cases := map[string][]int64{
+      "kick_sigma < 100": {1},
+      "kick_sigma >= 100": {2},
+      "kick_distribution = Maxwell": {1, 2},
+      "survival_fraction > 0": {1, 2},
+      "id != 1": {2},
+   }
+   for text, want := range cases {
+      f, err := ParseRunFilter(text)
+      if err != nil {
+         t.Fatal(err)
+      }
+      runs, err := QueryRuns(filename, []RunFilter{f})
+      if err != nil {
+         t.Fatal(err)
+      }
+      var got []int64
+      for _, r := range runs {
+         got = append(got, r.ID)
+      }
+      if !reflect.DeepEqual(got, want) {
+         t.Errorf("%s: got runs %v, want %v", text, got, want)
+      }
+   }
+
+}
+
+
+// filters must be on known fields, and only numbers can be ordered
+func TestParseRunFilterErrors (t *testing.T) {
+
+   for _, text := range []string{"kick_sigmas < 100", "kick_distribution < Maxwell", "kick_sigma 100", "seed ="} {
+      _, err := ParseRunFilter(text)
+      if err == nil {
+         t.Errorf("%s: got no error", text)
+      }
+   }
+
+}
+
+
+// numbers that are not finite are NULL, and tables of older databases get the columns they lack
+func TestSaveDatabaseMigration (t *testing.T) {
+
+   filename := filepath.Join(t.TempDir(), "orbits.db")
+   db, err := sql.Open("sqlite", filename)
+   if err != nil {
+      t.Fatal(err)
+   }
+   defer db.Close()
+   _, err = db.Exec("CREATE TABLE runs (id INTEGER PRIMARY KEY AUTOINCREMENT, timestamp TEXT, bounded_fraction REAL)")
+   if err == nil {
+      _, err = db.Exec("CREATE TABLE grid (run_id INTEGER, period REAL)")
+   }
+   if err != nil {
+      t.Fatal(err)
+   }
+
+   b := databaseTestBinary(t, 265)
+   b.ConvergenceTarget, b.AchievedError = "survival", math.Inf(1)
+   err = b.SaveDatabase(filename)
+   if err != nil {
+      t.Fatal(err)
+   }
+
+   var kind string
+   var kicks int64
+   err = db.QueryRow("SELECT typeof(achieved_error), number_of_kicks FROM runs").Scan(&kind, &kicks)
+   if err != nil {
+      t.Fatal(err)
+   }
+   if kind != "null" || kicks != 2000 {
+      t.Errorf("got achieved_error of type %s and %d kicks, want null and 2000", kind, kicks)
+   }
+   var cells int
+   err = db.QueryRow("SELECT COUNT(probability) FROM grid").Scan(&cells)
+   if err != nil {
+      t.Fatal(err)
+   }
+   if cells != len(b.ProbabilityGrid) {
+      t.Errorf("got %d cells with a probability in the grid, want %d", cells, len(b.ProbabilityGrid))
+   }
+
+}
@@ -0,0 +1,86 @@
+package orbits
+
+import (
+   "strconv"
+   "strings"
+)
+
+
+// error in the configuration: an option that is missing, invalid or inconsistent with others
+type ConfigError struct {
+   Option string
+   Message string
+}
+
+func (e *ConfigError) Error () string {
+
+   if e.Option == "" {
+      return "invalid configuration: " + e.Message
+   }
+
+   return "invalid configuration of `" + e.Option + "`: " + e.Message
+
+}
+
+
+// unknown distribution (or direction, or way of sampling) of kicks in an option
+type DistributionError struct {
+   Option string
+   Value string
+   Options []string
+}
+
+func (e *DistributionError) Error () string {
+
+   return "unknown `" + e.Option + "`: \"" + e.Value + "\", options are: " + strings.Join(e.Options, ", ")
+
+}
+
+
+// error reading or writing a file
+type IOError struct {
+   Filename string
+   Op string
+   Err error
+}
+
+func (e *IOError) Error () string {
+
+   return "unable to " + e.Op + " " + e.Filename + ": " + e.Err.Error()
+
+}
+
+func (e *IOError) Unwrap () error {
+
+   return e.Err
+
+}
+
+
+// grid that cannot be made, e.g. with less than two borders or no bounded binaries
+type GridError struct {
+   Message string
+}
+
+func (e *GridError) Error () string {
+
+   return "invalid grid: " + e.Message
+
+}
+
+
+// kicks drawn until the maximum number of cases without reaching the precision asked. Results
+// are still complete, with the precision achieved
+type ConvergenceError struct {
+   Target string
+   AchievedError float64
+   TargetError float64
+   NumberOfCases int
+}
+
+func (e *ConvergenceError) Error () string {
+
+   return "no convergence on " + e.Target + " after " + strconv.Itoa(e.NumberOfCases) + " kicks: error achieved " +
+      strconv.FormatFloat(e.AchievedError, 'E', 3, 64) + " (requested " + strconv.FormatFloat(e.TargetError, 'E', 3, 64) + ")"
+
+}
@@ -0,0 +1,188 @@
+package orbits
+
+import (
+   "encoding/binary"
+   "fmt"
+   "io"
+   "math"
+   "regexp"
+   "strconv"
+   "strings"
+)
+
+
+// FITS files are made of blocks of 2880 bytes, headers of 80-character cards
+const (
+   fitsBlock = 2880
+   fitsCard = 80
+)
+
+
+// FITS file with an empty primary HDU and a binary table extension (BINTABLE). Units of columns
+// go to TUNITn, keywords (the configuration) to HIERARCH cards and comments to COMMENT cards
+type fitsWriter struct{}
+
+func (fitsWriter) Write (w io.Writer, t Table) error {
+
+   primary := []string{
+      fitsLogical("SIMPLE", true),
+      fitsInteger("BITPIX", 8),
+      fitsInteger("NAXIS", 0),
+      fitsLogical("EXTEND", true),
+   }
+   err := writeFitsHeader(w, primary)
+   if err != nil {
+      return err
+   }
+
+   header := []string{
+      fitsString("XTENSION", "BINTABLE"),
+      fitsInteger("BITPIX", 8),
+      fitsInteger("NAXIS", 2),
+      fitsInteger("NAXIS1", 8 * len(t.Columns)),
+      fitsInteger("NAXIS2", t.Rows()),
+      fitsInteger("PCOUNT", 0),
+      fitsInteger("GCOUNT", 1),
+      fitsInteger("TFIELDS", len(t.Columns)),
+   }
+   for j, c := range t.Columns {
+      n := strconv.Itoa(j + 1)
+      header = append(header, fitsString("TTYPE" + n, c.Name))
+      if c.Integer {
+         header = append(header, fitsString("TFORM" + n, "1K"))
+      } else {
+         header = append(header, fitsString("TFORM" + n, "1D"))
+      }
+      if c.Unit != "" {
+         header = append(header, fitsString("TUNIT" + n, c.Unit))
+      }
+   }
+   if t.Name != "" {
+      header = append(header, fitsString("EXTNAME", strings.ToUpper(t.Name)))
+   }
+   for _, kw := range t.Keywords {
+      header = append(header, fitsHierarch(kw.Name, kw.Value)...)
+   }
+   for _, comment := range t.Comments {
+      header = append(header, fitsComment(comment)...)
+   }
+   err = writeFitsHeader(w, header)
+   if err != nil {
+      return err
+   }
+
+   // rows of big-endian values, padded with zeros to a whole block
+   row := make([]byte, 8 * len(t.Columns))
+   for k := 0; k < t.Rows(); k++ {
+      for j, c := range t.Columns {
+         if c.Integer {
+            binary.BigEndian.PutUint64(row[8*j:], uint64(int64(c.Values[k])))
+         } else {
+            binary.BigEndian.PutUint64(row[8*j:], math.Float64bits(c.Values[k]))
+         }
+      }
+      _, err := w.Write(row)
+      if err != nil {
+         return err
+      }
+   }
+   size := len(row) * t.Rows()
+   if size % fitsBlock != 0 {
+      _, err = w.Write(make([]byte, fitsBlock - size % fitsBlock))
+   }
+
+   return err
+
+}
+
+
+// write cards followed by END, padded with spaces to a whole block
+func writeFitsHeader (w io.Writer, cards []string) error {
+
+   str := strings.Join(cards, "") + fmt.Sprintf("%-80s", "END")
+   if len(str) % fitsBlock != 0 {
+      str += strings.Repeat(" ", fitsBlock - len(str) % fitsBlock)
+   }
+   _, err := io.WriteString(w, str)
+
+   return err
+
+}
+
+
+// card with a value in fixed format: keyword in columns 1-8, "= " and the value right-justified
+// up to column 30
+func fitsFixed (keyword string, value string) string {
+
+   return fmt.Sprintf("%-8s= %20s", keyword, value) + strings.Repeat(" ", fitsCard - 30)
+
+}
+
+func fitsLogical (keyword string, value bool) string {
+
+   if value {
+      return fitsFixed(keyword, "T")
+   }
+
+   return fitsFixed(keyword, "F")
+
+}
+
+func fitsInteger (keyword string, value int) string {
+
+   return fitsFixed(keyword, strconv.Itoa(value))
+
+}
+
+
+// card with a string value, quoted (with quotes doubled) and at least 8 characters long
+func fitsString (keyword string, value string) string {
+
+   quoted := "'" + fmt.Sprintf("%-8s", strings.ReplaceAll(value, "'", "''")) + "'"
+
+   return fmt.Sprintf("%-80s", fmt.Sprintf("%-8s= %s", keyword, quoted))
+
+}
+
+
+// decimal numbers, the only ones FITS can write unquoted (not Inf, NaN or hexadecimal floats)
+var fitsDecimal = regexp.MustCompile(`^[+-]?([0-9]+\.?[0-9]*|\.[0-9]+)([eE][+-]?[0-9]+)?$`)
+
+
+// HIERARCH card (ESO convention) for keywords longer than 8 characters. Values that are not
+// logical or decimal numbers are written as strings. HIERARCH cards cannot be continued, so values
+// that do not fit in one card (e.g. grid_axes) go to COMMENT cards, as "keyword: value"
+func fitsHierarch (keyword string, value string) []string {
+
+   prefix := "HIERARCH " + strings.ToUpper(keyword) + " = "
+
+   if value == "true" || value == "false" {
+      return []string{fmt.Sprintf("%-80s", prefix + strings.ToUpper(value[:1]))}
+   }
+   if fitsDecimal.MatchString(value) && len(prefix + value) <= fitsCard {
+      // exponents must be written with E
+      return []string{fmt.Sprintf("%-80s", prefix + strings.ToUpper(value))}
+   }
+
+   quoted := "'" + strings.ReplaceAll(value, "'", "''") + "'"
+   if len(prefix + quoted) <= fitsCard {
+      return []string{fmt.Sprintf("%-80s", prefix + quoted)}
+   }
+
+   return fitsComment(keyword + ": " + value)
+
+}
+
+
+// COMMENT cards with a text, split in pieces of 72 characters
+func fitsComment (text string) []string {
+
+   var cards []string
+   for len(text) > fitsCard - 8 {
+      cards = append(cards, "COMMENT " + text[:fitsCard - 8])
+      text = text[fitsCard - 8:]
+   }
+
+   return append(cards, fmt.Sprintf("%-80s", "COMMENT " + text))
+
+}
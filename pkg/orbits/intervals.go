@@ -0,0 +1,221 @@
+package orbits
+
+import (
+   "math"
+   "sort"
+
+   "golang.org/x/exp/rand"
+   "gonum.org/v1/gonum/floats"
+   "gonum.org/v1/gonum/stat/distuv"
+)
+
+
+// Wilson score interval of a binomial proportion f measured with n trials
+func WilsonInterval (f float64, n float64, level float64) [2]float64 {
+
+   if n <= 0 {
+      return [2]float64{0, 1}
+   }
+
+   z := distuv.UnitNormal.Quantile(0.5 + 0.5 * level)
+   z2 := z * z
+
+   center := (f + z2 / (2.0 * n)) / (1.0 + z2 / n)
+   half := z / (1.0 + z2 / n) * math.Sqrt(f * (1.0 - f) / n + z2 / (4.0 * n * n))
+
+   return [2]float64{math.Max(0, center - half), math.Min(1, center + half)}
+
+}
+
+
+// Clopper-Pearson (exact) interval of a binomial proportion f measured with n trials. The
+// number of successes, f*n, does not need to be an integer
+func ClopperPearsonInterval (f float64, n float64, level float64) [2]float64 {
+
+   if n <= 0 {
+      return [2]float64{0, 1}
+   }
+
+   alpha := 1.0 - level
+   k := f * n
+
+   lower := 0.0
+   if k > 0 {
+      lower = distuv.Beta{Alpha: k, Beta: n - k + 1}.Quantile(0.5 * alpha)
+   }
+
+   upper := 1.0
+   if k < n {
+      upper = distuv.Beta{Alpha: k + 1, Beta: n - k}.Quantile(1.0 - 0.5 * alpha)
+   }
+
+   return [2]float64{lower, upper}
+
+}
+
+
+// Goodman (1965) simultaneous intervals for the proportions of a multinomial distribution with
+// n trials, one for each element of probabilities
+func MultinomialIntervals (probabilities []float64, n float64, level float64) [][2]float64 {
+
+   intervals := make([][2]float64, len(probabilities))
+   if n <= 0 || len(probabilities) == 0 {
+      for k, _ := range intervals {
+         intervals[k] = [2]float64{0, 1}
+      }
+      return intervals
+   }
+
+   // Bonferroni correction over the number of cells
+   alpha := (1.0 - level) / float64(len(probabilities))
+   a := distuv.ChiSquared{K: 1}.Quantile(1.0 - alpha)
+
+   for k, p := range probabilities {
+      nk := p * n
+      half := math.Sqrt(a * (a + 4.0 * nk * (n - nk) / n))
+      intervals[k][0] = math.Max(0, (a + 2.0 * nk - half) / (2.0 * (n + a)))
+      intervals[k][1] = math.Min(1, (a + 2.0 * nk + half) / (2.0 * (n + a)))
+   }
+
+   return intervals
+
+}
+
+
+// confidence intervals of the fraction of kicks that leave the binary bounded. Weighted kicks
+// use their effective number as the number of trials
+func (b *Binary) boundedFractionIntervals () {
+
+   f := b.BoundedFraction()
+   n := b.effectiveNumberOfKicks()
+
+   b.BoundedFractionWilson = WilsonInterval(f, n, b.ConfidenceLevel)
+   b.BoundedFractionClopperPearson = ClopperPearsonInterval(f, n, b.ConfidenceLevel)
+
+}
+
+
+// confidence intervals of the probability of each cell of a grid of period & eccentricity,
+// with rows being eccentricities and columns periods (as in histogramOfOrbits)
+func (b *Binary) gridIntervals (pBorders []float64, eBorders []float64, probabilities [][]float64) [][][2]float64 {
+
+   nRows := len(probabilities)
+   nCols := len(probabilities[0])
+
+   // flatten the grid, cells are numbered row by row
+   flat := make([]float64, 0, nRows * nCols)
+   for i := 0; i < nRows; i++ {
+      flat = append(flat, probabilities[i]...)
+   }
+   cells := make([]int, len(b.IndexBounded))
+   for k, _ := range b.IndexBounded {
+      i := cellIndex(b.EccentricityBounded[k], eBorders)
+      j := cellIndex(b.PeriodBounded[k], pBorders)
+      cells[k] = -1
+      if i >= 0 && j >= 0 {
+         cells[k] = i * nCols + j
+      }
+   }
+
+   flatIntervals := b.cellIntervals(flat, cells)
+
+   intervals := make([][][2]float64, nRows)
+   for i := 0; i < nRows; i++ {
+      intervals[i] = flatIntervals[i*nCols:(i+1)*nCols]
+   }
+
+   return intervals
+
+}
+
+
+// confidence intervals of the probability of each cell of a grid of any shape. cells has the
+// index of the cell of each bounded binary, -1 for binaries outside of the grid
+func (b *Binary) cellIntervals (probabilities []float64, cells []int) [][2]float64 {
+
+   intervals := make([][2]float64, len(probabilities))
+
+   switch b.GridIntervalMethod {
+   case "multinomial":
+      intervals = MultinomialIntervals(probabilities, b.effectiveNumberOfBounded(), b.ConfidenceLevel)
+
+   case "bootstrap":
+      samples := b.bootstrapCells(cells, len(probabilities))
+      lowerQ := 0.5 * (1.0 - b.ConfidenceLevel)
+      upperQ := 1.0 - lowerQ
+      for c, _ := range samples {
+         sort.Float64s(samples[c])
+         intervals[c][0] = WeightedQuantile(lowerQ, samples[c], unitWeights(len(samples[c])))
+         intervals[c][1] = WeightedQuantile(upperQ, samples[c], unitWeights(len(samples[c])))
+      }
+   }
+
+   return intervals
+
+}
+
+
+// probability of each of nCells cells for BootstrapSamples resamples (with replacement) of the
+// bounded binaries, where cells is the index of the cell of each of them. Resamples are drawn
+// from a stream seeded with Seed, so they are reproducible
+func (b *Binary) bootstrapCells (cells []int, nCells int) [][]float64 {
+
+   samples := make([][]float64, nCells)
+   for c := 0; c < nCells; c++ {
+      samples[c] = make([]float64, b.BootstrapSamples)
+   }
+
+   nBounded := len(cells)
+   if nBounded == 0 {
+      return samples
+   }
+
+   src := rand.New(rand.NewSource(b.Seed))
+   for s := 0; s < b.BootstrapSamples; s++ {
+      total := 0.0
+      for k := 0; k < nBounded; k++ {
+         l := src.Intn(nBounded)
+         total += b.WeightBounded[l]
+         if cells[l] >= 0 {
+            samples[cells[l]][s] += b.WeightBounded[l]
+         }
+      }
+      if total > 0 {
+         for c := 0; c < nCells; c++ {
+            samples[c][s] /= total
+         }
+      }
+   }
+
+   return samples
+
+}
+
+
+// index of the interval [borders[k], borders[k+1]) that contains x, -1 if outside of borders.
+// The last interval includes its upper border, so that no value inside the borders is lost
+func cellIndex (x float64, borders []float64) int {
+
+   for k := 0; k < len(borders)-1; k++ {
+      if x >= borders[k] && x < borders[k+1] {
+         return k
+      }
+   }
+   if len(borders) > 1 && x == borders[len(borders)-1] {
+      return len(borders) - 2
+   }
+
+   return -1
+
+}
+
+
+// slice of n ones
+func unitWeights (n int) []float64 {
+
+   w := make([]float64, n)
+   floats.AddConst(1.0, w)
+
+   return w
+
+}
@@ -0,0 +1,32 @@
+package orbits
+
+import (
+   "math"
+   "testing"
+)
+
+
+// 95% intervals of binomial proportions, against tabulated values and the closed forms of
+// Clopper-Pearson with no (or only) successes
+func TestBinomialIntervalsKnownValues (t *testing.T) {
+
+   cases := []struct {
+      name string
+      got [2]float64
+      want [2]float64
+   }{
+      {"Wilson 5/10", WilsonInterval(0.5, 10, 0.95), [2]float64{0.2366, 0.7634}},
+      {"Wilson 0/10", WilsonInterval(0, 10, 0.95), [2]float64{0, 0.2775}},
+      {"Wilson 81/263", WilsonInterval(81.0/263.0, 263, 0.95), [2]float64{0.2553, 0.3662}},
+      {"Clopper-Pearson 5/10", ClopperPearsonInterval(0.5, 10, 0.95), [2]float64{0.1871, 0.8129}},
+      {"Clopper-Pearson 0/10", ClopperPearsonInterval(0, 10, 0.95), [2]float64{0, 1 - math.Pow(0.025, 0.1)}},
+      {"Clopper-Pearson 10/10", ClopperPearsonInterval(1, 10, 0.95), [2]float64{math.Pow(0.025, 0.1), 1}},
+   }
+
+   for _, c := range cases {
+      if math.Abs(c.got[0] - c.want[0]) > 1e-4 || math.Abs(c.got[1] - c.want[1]) > 1e-4 {
+         t.Errorf("%s: got %v, want %v", c.name, c.got, c.want)
+      }
+   }
+
+}
@@ -1,159 +1,667 @@
 package orbits
 
 import (
+   "bufio"
+   "encoding/json"
+   "errors"
    "fmt"
+   "math"
 	"os"
+   "path/filepath"
+   "reflect"
    "strconv"
+   "strings"
 	"io/ioutil"
 	
    "github.com/asimazbunzel/go-orbits/pkg/io"
 
+	"gonum.org/v1/gonum/floats"
 	"gopkg.in/yaml.v3"
 )
 
 
-// read information on binary and kicks from YAML file
-func (b *Binary) parseYAML (filename string) error {
+// read options of a run from a YAML file, on top of the defaults
+func LoadConfig (filename string) (Config, error) {
+
+   c := DefaultConfig()
 
    // read YAML data file into bytes 
    data, err := ioutil.ReadFile(filename)
    if err != nil {
-      io.LogError("ORBITS - orbits.go - ParseYAML", "problem reading YAML file")
+      return c, &IOError{Filename: filename, Op: "read", Err: err}
    }
    
-   return yaml.Unmarshal(data, b)
+   err = yaml.Unmarshal(data, &c)
+   if err != nil {
+      return c, &ConfigError{Message: err.Error()}
+   }
+
+   return c, nil
 }
 
 
-// save kick info to file
-func (b *Binary) SaveKicks (filename string) {
+// options of the configuration, as resolved (defaults included), in astro units. Values that are
+// not numbers, strings or logicals are written as JSON
+func (b *Binary) ConfigKeywords () []Keyword {
 
-   if b.LogLevel != "none"{
-      io.LogInfo("ORBITS - orbits.go - SaveKicks", "saving kicks information")
+   var keywords []Keyword
+   v := reflect.ValueOf(b.Config)
+   for k := 0; k < v.NumField(); k++ {
+      name := v.Type().Field(k).Tag.Get("yaml")
+      if name == "" {
+         continue
+      }
+      field := v.Field(k)
+      value := fmt.Sprint(field.Interface())
+      switch field.Kind() {
+      case reflect.Slice, reflect.Array, reflect.Struct, reflect.Map:
+         data, err := json.Marshal(field.Interface())
+         if err == nil {
+            value = string(data)
+         }
+      }
+      keywords = append(keywords, Keyword{Name: name, Value: value})
+   }
+
+   return keywords
+
+}
+
+
+// write a table to filename in format
+func writeTable (filename string, format string, t Table) error {
+
+   tw, err := NewTableWriter(format)
+   if err != nil {
+      return &ConfigError{Message: err.Error()}
    }
 
    // create file
    f, err := os.Create(filename)
    if err != nil {
-      io.LogError("error writing to file", "open file")
+      return &IOError{Filename: filename, Op: "create", Err: err}
    }
 
    // remember to close the file
    defer f.Close()
 
-   // header
-   column_names := [4]string{"id", "w", "theta", " phi"}
-   str := fmt.Sprintf("%20s", column_names[0]) 
-   str += fmt.Sprintf("%20s", column_names[1])
-   str += fmt.Sprintf("%20s", column_names[2])
-   str += fmt.Sprintf("%20s\n", column_names[3])
-   _, err = f.WriteString(str)
+   buf := bufio.NewWriter(f)
+   err = tw.Write(buf, t)
+   if err == nil {
+      err = buf.Flush()
+   }
    if err != nil {
-      io.LogError("ORBITS - orbits.go - SaveKicks", "error writing header to file")
+      return &IOError{Filename: filename, Op: "write", Err: err}
    }
 
-   // write rows of different natal kicks
-   for k, w := range b.W {
-      str := fmt.Sprintf("%20s", strconv.Itoa(k))
-      str += fmt.Sprintf("%20s", strconv.FormatFloat(w, 'E', 5, 64))
-      str += fmt.Sprintf("%20s",strconv.FormatFloat(b.Theta[k], 'E', 5, 64))
-      str += fmt.Sprintf("%20s\n",strconv.FormatFloat(b.Phi[k], 'E', 5, 64))
-      _, err := f.WriteString(str)
+   // formats that cannot hold keywords & units get them in a sidecar file
+   if !embedsMetadata(format) {
+      return writeManifest(filename, format, t)
+   }
+
+   return nil
+
+}
+
+
+// check the format of every output that is saved, before the run
+func (b *Binary) checkOutputFormats () error {
+
+   formats := []struct {
+      option string
+      format string
+      store bool
+   }{
+      {"kicks_format", b.KicksFormat, b.StoreKicks},
+      {"bounded_orbits_format", b.BoundedOrbitsFormat, b.StoreOrbits},
+      {"grid_of_orbits_format", b.GridFormat, b.StoreGrid},
+      {"representative_orbits_format", b.RepresentativeOrbitsFormat, b.StoreRepresentativeOrbits},
+      {"multidim_grid_format", b.MultiGridFormat, b.StoreMultiGrid},
+      {"marginals_format", b.MarginalsFormat, b.StoreMultiGrid},
+      {"kick_theta_map_format", b.KickThetaMapFormat, b.StoreSurvivalMaps},
+      {"theta_phi_map_format", b.ThetaPhiMapFormat, b.StoreSurvivalMaps},
+      {"survival_boundary_format", b.SurvivalBoundaryFormat, b.StoreSurvivalMaps},
+   }
+   for _, f := range formats {
+      if !f.store {
+         continue
+      }
+      _, err := NewTableWriter(f.format)
       if err != nil {
-         io.LogError("ORBITS - orbits.go - SaveKicks", "error writing info to file")
+         return &ConfigError{Option: f.option, Message: err.Error()}
       }
    }
 
+   return nil
+
+}
+
+
+// write the metadata of a table written to filename in format to its sidecar file
+func writeManifest (filename string, format string, t Table) error {
+
+   data, err := json.MarshalIndent(newManifest(filename, format, t), "", "  ")
+   if err == nil {
+      err = ioutil.WriteFile(filename + ".meta.json", data, 0644)
+   }
+   if err != nil {
+      return &IOError{Filename: filename + ".meta.json", Op: "write", Err: err}
+   }
+
+   return nil
+
+}
+
+
+// save kick info to file
+func (b *Binary) SaveKicks (filename string) error {
+
+   if b.LogLevel != "none"{
+      io.LogInfo("ORBITS - orbits.go - SaveKicks", "saving kicks information")
+   }
+
+   return writeTable(filename, b.KicksFormat, b.KicksTable())
+
+}
+
+
+// table of natal kicks, one row per kick
+func (b *Binary) KicksTable () Table {
+
+   id := make([]float64, len(b.W))
+   for k, _ := range id {
+      id[k] = float64(b.firstKick + k)
+   }
+
+   return Table{Name: "kicks", Keywords: b.MetadataKeywords(), Columns: []Column{
+      {Name: "id", Values: id, Integer: true},
+      {Name: "w", Values: b.W, Unit: "km/s"},
+      {Name: "theta", Values: b.Theta, Unit: "rad"},
+      {Name: "phi", Values: b.Phi, Unit: "rad"},
+      {Name: "weight", Values: b.Weight},
+   }}
+
 }
 
 
 // save orbits info to file
-func (b *Binary) SaveBoundedOrbits (filename string) {
+func (b *Binary) SaveBoundedOrbits (filename string) error {
 
    if b.LogLevel != "none"{
       io.LogInfo("ORBITS - orbits.go - SaveBoundedOrbits", "saving orbits information")
    }
 
-   // create file
-   f, err := os.Create(filename)
-   if err != nil {
-      io.LogError("error writing to file", "open file")
+   return writeTable(filename, b.BoundedOrbitsFormat, b.BoundedOrbitsTable())
+
+}
+
+
+// table of binaries bounded after the kick, one row per binary with the id of its kick
+func (b *Binary) BoundedOrbitsTable () Table {
+
+   id := make([]float64, len(b.IndexBounded))
+   for k, kb := range b.IndexBounded {
+      id[k] = float64(kb)
    }
 
-   // remember to close the file
-   defer f.Close()
+   return Table{Name: "orbits", Keywords: b.MetadataKeywords(), Columns: []Column{
+      {Name: "id", Values: id, Integer: true},
+      {Name: "w", Values: b.WBounded, Unit: "km/s"},
+      {Name: "theta", Values: b.ThetaBounded, Unit: "rad"},
+      {Name: "phi", Values: b.PhiBounded, Unit: "rad"},
+      {Name: "period", Values: b.PeriodBounded, Unit: "d"},
+      {Name: "separation", Values: b.SeparationBounded, Unit: "Rsun"},
+      {Name: "eccentricity", Values: b.EccentricityBounded},
+      {Name: "weight", Values: b.WeightBounded},
+      {Name: "vsys", Values: b.VsysBounded, Unit: "km/s"},
+   }}
+
+}
+
+
+// save grid of binaries bounded after kick
+func (b *Binary) SaveGridOrbits (filename string) error {
+
+   if b.LogLevel != "none"{
+      io.LogInfo("ORBITS - orbits.go - SaveGridOrbits", "saving grid of orbits information")
+   }
+
+   return writeTable(filename, b.GridFormat, b.GridTable())
+
+}
+
+
+// table of cells of the grid above a minimum probability, with the borders of the grid and the
+// probability left out of it as comments
+func (b *Binary) GridTable () Table {
+
+   id := make([]float64, len(b.PeriodGrid))
+   for k, _ := range id {
+      id[k] = float64(k)
+   }
+
+   pEdges := "period_edges:"
+   for _, p := range b.PeriodBordersGrid {
+      pEdges += " " + strconv.FormatFloat(p, 'E', 5, 64)
+   }
+   eEdges := "eccentricity_edges:"
+   for _, e := range b.EccentricityBordersGrid {
+      eEdges += " " + strconv.FormatFloat(e, 'E', 5, 64)
+   }
+   comments := []string{
+      pEdges,
+      eEdges,
+      "normalization: " + b.GridNormalization,
+      "probability_outside_grid: " + strconv.FormatFloat(b.ProbabilityOutsideGrid, 'E', 5, 64),
+      "probability_below_threshold: " + strconv.FormatFloat(b.ProbabilityBelowThreshold, 'E', 5, 64),
+   }
 
-   // header
-   column_names := [7]string{"id", "w", "theta", "phi", "period", "separation", "eccentricity"}
-   str := fmt.Sprintf("%20s", column_names[0]) 
-   str += fmt.Sprintf("%20s", column_names[1])
-   str += fmt.Sprintf("%20s", column_names[2])
-   str += fmt.Sprintf("%20s", column_names[3])
-   str += fmt.Sprintf("%20s", column_names[4]) 
-   str += fmt.Sprintf("%20s", column_names[5])
-   str += fmt.Sprintf("%20s\n", column_names[6])
-   _, err = f.WriteString(str)
+   return Table{Name: "grid", Keywords: b.MetadataKeywords(), Comments: comments, Columns: []Column{
+      {Name: "id", Values: id, Integer: true},
+      {Name: "period", Values: b.PeriodGrid, Unit: "d"},
+      {Name: "separation", Values: b.SeparationGrid, Unit: "Rsun"},
+      {Name: "eccentricity", Values: b.EccentricityGrid},
+      {Name: "probability", Values: b.ProbabilityGrid},
+      {Name: "probability_lower", Values: b.ProbabilityLowerGrid},
+      {Name: "probability_upper", Values: b.ProbabilityUpperGrid},
+      {Name: "period_lower", Values: b.PeriodLowerGrid, Unit: "d"},
+      {Name: "period_upper", Values: b.PeriodUpperGrid, Unit: "d"},
+      {Name: "eccentricity_lower", Values: b.EccentricityLowerGrid},
+      {Name: "eccentricity_upper", Values: b.EccentricityUpperGrid},
+   }}
+
+}
+
+
+// full grid of probabilities, as written to JSON. Rows are eccentricities and columns periods
+type GridMatrix struct {
+   Rows string `json:"rows"`
+   Columns string `json:"columns"`
+   PeriodEdges []float64 `json:"period_edges"`
+   EccentricityEdges []float64 `json:"eccentricity_edges"`
+   PeriodUnit string `json:"period_unit"`
+   Normalization string `json:"normalization"`
+   ConfidenceLevel float64 `json:"confidence_level"`
+   Probability [][]float64 `json:"probability"`
+   ProbabilityLower [][]float64 `json:"probability_lower"`
+   ProbabilityUpper [][]float64 `json:"probability_upper"`
+   ProbabilityOutsideGrid float64 `json:"probability_outside_grid"`
+   Metadata map[string]string `json:"metadata"`
+}
+
+
+// save every cell of the grid of orbits (not only those above a minimum probability) as a JSON
+// matrix, together with the edges of its axes
+func (b *Binary) SaveGridMatrix (filename string) error {
+
+   if b.LogLevel != "none"{
+      io.LogInfo("ORBITS - io.go - SaveGridMatrix", "saving matrix of grid of orbits")
+   }
+
+   if b.ProbabilityMatrix == nil {
+      return &GridError{Message: "matrix of probabilities only available for regular grids"}
+   }
+
+   m := GridMatrix{
+      Rows: "eccentricity",
+      Columns: "period",
+      PeriodEdges: b.PeriodBordersGrid,
+      EccentricityEdges: b.EccentricityBordersGrid,
+      PeriodUnit: "days",
+      Normalization: b.GridNormalization,
+      ConfidenceLevel: b.ConfidenceLevel,
+      Probability: b.ProbabilityMatrix,
+      ProbabilityLower: b.ProbabilityLowerMatrix,
+      ProbabilityUpper: b.ProbabilityUpperMatrix,
+      ProbabilityOutsideGrid: b.ProbabilityOutsideGrid,
+      Metadata: make(map[string]string),
+   }
+   for _, kw := range b.MetadataKeywords() {
+      m.Metadata[kw.Name] = kw.Value
+   }
+
+   data, err := json.MarshalIndent(m, "", "  ")
    if err != nil {
-      io.LogError("ORBITS - orbits.go - SaveBoundedOrbits", "error writing header to file")
+      return &IOError{Filename: filename, Op: "encode", Err: err}
    }
 
-   // write rows of different natal kicks
-   for k, kb := range b.IndexBounded {
-      str := fmt.Sprintf("%20s", strconv.Itoa(kb))
-      str += fmt.Sprintf("%20s", strconv.FormatFloat(b.WBounded[k], 'E', 5, 64))
-      str += fmt.Sprintf("%20s", strconv.FormatFloat(b.ThetaBounded[k], 'E', 5, 64))
-      str += fmt.Sprintf("%20s", strconv.FormatFloat(b.PhiBounded[k], 'E', 5, 64))
-      str += fmt.Sprintf("%20s", strconv.FormatFloat(b.PeriodBounded[k], 'E', 5, 64))
-      str += fmt.Sprintf("%20s",  strconv.FormatFloat(b.SeparationBounded[k], 'E', 5, 64))
-      str += fmt.Sprintf("%20s\n",  strconv.FormatFloat(b.EccentricityBounded[k], 'E', 5, 64))
-      _, err := f.WriteString(str)
-      if err != nil {
-         io.LogError("ORBITS - orbits.go - SaveBoundedOrbits", "error writing info to file")
+   err = ioutil.WriteFile(filename, data, 0644)
+   if err != nil {
+      return &IOError{Filename: filename, Op: "write", Err: err}
+   }
+
+   return nil
+
+}
+
+
+// save a survival map in kick space in format, one row per bin
+func (b *Binary) SaveSurvivalMap (filename string, format string, m SurvivalMap, xName string, yName string) error {
+
+   if b.LogLevel != "none"{
+      io.LogInfo("ORBITS - io.go - SaveSurvivalMap", "saving survival map in (" + xName + ", " + yName + ")")
+   }
+
+   nBins := len(m.Fraction) * (len(m.XEdges) - 1)
+   columns := []Column{
+      {Name: xName + "_lower", Unit: kickUnit(xName)},
+      {Name: xName + "_upper", Unit: kickUnit(xName)},
+      {Name: yName + "_lower", Unit: kickUnit(yName)},
+      {Name: yName + "_upper", Unit: kickUnit(yName)},
+      {Name: "number", Integer: true},
+      {Name: "survival_fraction"},
+      {Name: "mean_separation", Unit: "Rsun"},
+      {Name: "mean_eccentricity"},
+   }
+   for j, _ := range columns {
+      columns[j].Values = make([]float64, 0, nBins)
+   }
+   for i, _ := range m.Fraction {
+      for j, _ := range m.Fraction[i] {
+         row := []float64{m.XEdges[j], m.XEdges[j+1], m.YEdges[i], m.YEdges[i+1], float64(m.Number[i][j]), m.Fraction[i][j], m.MeanSeparation[i][j], m.MeanEccentricity[i][j]}
+         for c, x := range row {
+            columns[c].Values = append(columns[c].Values, x)
+         }
       }
    }
 
+   t := Table{Name: xName + "_" + yName + "_map", Keywords: b.MetadataKeywords(), Columns: columns}
+
+   return writeTable(filename, format, t)
+
 }
 
 
-// save grid of binaries bounded after kick
-func (b *Binary) SaveGridOrbits (filename string) {
+// unit of a parameter of kicks
+func kickUnit (name string) string {
+
+   if name == "w" {
+      return "km/s"
+   }
+
+   return "rad"
+
+}
+
+
+// save analytic boundaries of the kicks that leave the binary bounded
+func (b *Binary) SaveSurvivalBoundary (filename string) error {
 
    if b.LogLevel != "none"{
-      io.LogInfo("ORBITS - orbits.go - SaveGridOrbits", "saving grid of orbits information")
+      io.LogInfo("ORBITS - io.go - SaveSurvivalBoundary", "saving boundaries of bounded binaries in kick space")
    }
 
-   // create file
-   f, err := os.Create(filename)
+   t := Table{Name: "survival_boundary", Keywords: b.MetadataKeywords(), Columns: []Column{
+      {Name: "theta", Values: b.ThetaBoundary, Unit: "rad"},
+      {Name: "min_kick_bounded", Values: b.MinKickBoundary, Unit: "km/s"},
+      {Name: "max_kick_bounded", Values: b.MaxKickBoundary, Unit: "km/s"},
+   }}
+
+   return writeTable(filename, b.SurvivalBoundaryFormat, t)
+
+}
+
+
+// save representative orbits of the bounded binaries
+func (b *Binary) SaveRepresentativeOrbits (filename string) error {
+
+   if b.LogLevel != "none"{
+      io.LogInfo("ORBITS - io.go - SaveRepresentativeOrbits", "saving representative orbits information")
+   }
+
+   id := make([]float64, len(b.PeriodRepresentative))
+   for k, _ := range id {
+      id[k] = float64(k)
+   }
+
+   t := Table{Name: "representative", Keywords: b.MetadataKeywords(), Columns: []Column{
+      {Name: "id", Values: id, Integer: true},
+      {Name: "period", Values: b.PeriodRepresentative, Unit: "d"},
+      {Name: "separation", Values: b.SeparationRepresentative, Unit: "Rsun"},
+      {Name: "eccentricity", Values: b.EccentricityRepresentative},
+      {Name: "vsys", Values: b.VsysRepresentative, Unit: "km/s"},
+      {Name: "probability", Values: b.ProbabilityRepresentative},
+   }}
+
+   return writeTable(filename, b.RepresentativeOrbitsFormat, t)
+
+}
+
+
+// save cells of the multi-dimensional grid above a minimum probability, with the borders &
+// centre of each cell for every axis
+func (b *Binary) SaveMultiGrid (filename string) error {
+
+   if b.LogLevel != "none"{
+      io.LogInfo("ORBITS - io.go - SaveMultiGrid", "saving multi-dimensional grid of orbits")
+   }
+
+   // id, then centre, lower & upper border of every axis, then probability
+   columns := []Column{{Name: "id", Integer: true}}
+   for _, axis := range b.GridAxes {
+      unit := astroUnit(axis.Quantity)
+      columns = append(columns, Column{Name: axis.Quantity, Unit: unit}, Column{Name: axis.Quantity + "_lower", Unit: unit}, Column{Name: axis.Quantity + "_upper", Unit: unit})
+   }
+   columns = append(columns, Column{Name: "probability"})
+
+   id := 0
+   for cell, probability := range b.MultiGridProbability {
+      if probability <= b.MinProb {
+         continue
+      }
+      row := []float64{float64(id)}
+      for d, k := range b.multiGridBins(cell) {
+         lower := b.MultiGridEdges[d][k]
+         upper := b.MultiGridEdges[d][k+1]
+         center := 0.5 * (lower + upper)
+         if b.GridAxes[d].Scale == "log" {
+            center = math.Sqrt(lower * upper)
+         }
+         row = append(row, center, lower, upper)
+      }
+      row = append(row, probability)
+      for c, x := range row {
+         columns[c].Values = append(columns[c].Values, x)
+      }
+      id++
+   }
+
+   t := Table{Name: "multidim_grid", Keywords: b.MetadataKeywords(), Columns: columns}
+
+   return writeTable(filename, b.MultiGridFormat, t)
+
+}
+
+
+// save 1D histograms of each axis of the multi-dimensional grid, one row per bin with the index
+// of its axis in grid_axes. Borders are in the units of the quantity of each axis
+func (b *Binary) SaveMarginals (filename string) error {
+
+   if b.LogLevel != "none"{
+      io.LogInfo("ORBITS - io.go - SaveMarginals", "saving marginal histograms of grid axes")
+   }
+
+   var axis, lower, upper, probability []float64
+   axes := "axes:"
+   for d, marginal := range b.MultiGridMarginals {
+      quantity := b.GridAxes[d].Quantity
+      axes += " " + strconv.Itoa(d) + "=" + quantity
+      if unit := astroUnit(quantity); unit != "" {
+         axes += "[" + unit + "]"
+      }
+      for k, p := range marginal {
+         axis = append(axis, float64(d))
+         lower = append(lower, b.MultiGridEdges[d][k])
+         upper = append(upper, b.MultiGridEdges[d][k+1])
+         probability = append(probability, p)
+      }
+   }
+
+   t := Table{Name: "marginals", Keywords: b.MetadataKeywords(), Comments: []string{axes}, Columns: []Column{
+      {Name: "axis", Values: axis, Integer: true},
+      {Name: "lower", Values: lower},
+      {Name: "upper", Values: upper},
+      {Name: "probability", Values: probability},
+   }}
+
+   return writeTable(filename, b.MarginalsFormat, t)
+
+}
+
+
+// read the columns of a table in format (guessed from the extension of filename if empty), by
+// their name
+func ReadColumns (filename string, format string) (map[string][]float64, error) {
+
+   t, err := ReadTable(filename, format)
    if err != nil {
-      io.LogError("error writing to file", "open file")
+      return nil, err
    }
 
-   // remember to close the file
-   defer f.Close()
+   columns := make(map[string][]float64)
+   for _, c := range t.Columns {
+      columns[c.Name] = c.Values
+   }
+
+   return columns, nil
+
+}
+
+
+// read kicks from a file instead of drawing them, to replay a sample with new physics. The file
+// can be one saved by SaveKicks or made by another code: its columns are found by the names of
+// ReplayConvention (w, theta, phi and, if present, weight), unless changed in ReplayColumns, and
+// its angles are converted from that convention. Strengths are in km/s and angles in radians
+// unless the units of the columns say otherwise. Kicks are replayed as they are, without any
+// reduction by fallback
+func (b *Binary) LoadKicks (filename string, format string) error {
+
+   if b.LogLevel != "none" {
+      io.LogInfo("ORBITS - io.go - LoadKicks", "loading kicks from " + filename)
+   }
+
+   convention, err := KickConventionByName(b.ReplayConvention)
+   if err != nil {
+      return &ConfigError{Option: "replay_convention", Message: err.Error()}
+   }
+   names := make(map[string]string)
+   for quantity, name := range convention.Columns {
+      names[quantity] = name
+   }
+   for quantity, name := range b.ReplayColumns {
+      if _, ok := names[quantity]; !ok {
+         return &ConfigError{Option: "replay_columns", Message: "unknown quantity " + quantity + ", options are: w, theta, phi, weight"}
+      }
+      names[quantity] = name
+   }
 
-   // header
-   column_names := [5]string{"id", "period", "separation", "eccentricity", "probability"}
-   str := fmt.Sprintf("%20s", column_names[0]) 
-   str += fmt.Sprintf("%20s", column_names[1])
-   str += fmt.Sprintf("%20s", column_names[2])
-   str += fmt.Sprintf("%20s", column_names[3])
-   str += fmt.Sprintf("%20s\n", column_names[4]) 
-   _, err = f.WriteString(str)
+   if format == "" {
+      format = formatFromExtension(filename)
+   }
+   tr, err := NewTableReader(format)
    if err != nil {
-      io.LogError("ORBITS - orbits.go - SaveGridOrbits", "error writing header to file")
+      return &ConfigError{Option: "replay_kicks_format", Message: err.Error()}
+   }
+   // codes with rows of other headers above the column names only write them in text & csv
+   if convention.HeaderRows > 0 {
+      ext := strings.ToLower(filepath.Ext(filename))
+      if (format != "text" && format != "csv") || ext == ".h5" || ext == ".hdf5" {
+         return &ConfigError{Option: "replay_kicks_format", Message: "kicks of " + convention.Name + " can only be replayed from its CSV, TSV or TXT logs (HDF5 is not supported)"}
+      }
+      tr = headerRowsReader{TableReader: tr, rows: convention.HeaderRows, name: names["w"]}
+   }
+   t, err := readTable(filename, format, tr)
+   if err != nil {
+      return err
    }
 
-   // write info to file
-   for k, _ := range b.PeriodGrid {
-      str := fmt.Sprintf("%20s", strconv.Itoa(k))
-      str += fmt.Sprintf("%20s", strconv.FormatFloat(b.PeriodGrid[k], 'E', 5, 64))
-      str += fmt.Sprintf("%20s", strconv.FormatFloat(b.SeparationGrid[k], 'E', 5, 64))
-      str += fmt.Sprintf("%20s", strconv.FormatFloat(b.EccentricityGrid[k], 'E', 5, 64))
-      str += fmt.Sprintf("%20s\n", strconv.FormatFloat(b.ProbabilityGrid[k], 'E', 5, 64))
-      _, err := f.WriteString(str)
+   values := make(map[string][]float64)
+   for _, quantity := range []string{"w", "theta", "phi", "weight"} {
+      c, ok := t.Column(names[quantity])
+      if !ok {
+         if quantity == "weight" {
+            values[quantity] = unitWeights(t.Rows())
+            continue
+         }
+         return &IOError{Filename: filename, Op: "replay kicks from", Err: errors.New("column " + names[quantity] + " (" + quantity + ") not found")}
+      }
+      factor, err := replayFactor(quantity, c.Unit)
       if err != nil {
-         io.LogError("ORBITS - orbits.go - SaveGridOrbits", "error writing info to file")
+         return &IOError{Filename: filename, Op: "replay kicks from", Err: err}
+      }
+      values[quantity] = make([]float64, len(c.Values))
+      floats.ScaleTo(values[quantity], factor, c.Values)
+   }
+
+   // angles in the frame of OrbitsAfterKicks
+   for k, _ := range values["theta"] {
+      values["theta"][k], values["phi"][k] = convention.ToFrame(values["theta"][k], values["phi"][k])
+   }
+
+   // kicks (and every result) of previous calls are replaced
+   b.Reset()
+   b.W = values["w"]
+   b.Theta = values["theta"]
+   b.Phi = values["phi"]
+   b.Weight = values["weight"]
+   b.NumberOfCases = len(b.W)
+   if b.cgs {
+      floats.Scale(km2cm, b.W)
+   }
+
+   if b.LogLevel != "none" {
+      io.LogInfo("ORBITS - io.go - LoadKicks", "loaded " + strconv.Itoa(b.NumberOfCases) + " kicks")
+   }
+
+   return nil
+
+}
+
+
+// factor to change a quantity of kicks from unit to km/s (strength) or radians (angles)
+func replayFactor (quantity string, unit string) (float64, error) {
+
+   switch quantity {
+   case "w":
+      switch unit {
+      case "", "km/s":
+         return 1.0, nil
+      case "m/s":
+         return 1e-3, nil
+      case "cm/s":
+         return 1.0 / km2cm, nil
+      }
+   case "theta", "phi":
+      switch unit {
+      case "", "rad":
+         return 1.0, nil
+      case "deg":
+         return math.Pi / 180.0, nil
       }
+   case "weight":
+      return 1.0, nil
    }
 
+   return 0, errors.New("unknown unit of " + quantity + ": " + unit)
+
+}
+
+
+// read a matrix of the grid of orbits saved by SaveGridMatrix
+func ReadGridMatrix (filename string) (GridMatrix, error) {
+
+   var m GridMatrix
+
+   data, err := ioutil.ReadFile(filename)
+   if err != nil {
+      return m, &IOError{Filename: filename, Op: "read", Err: err}
+   }
+
+   err = json.Unmarshal(data, &m)
+   if err != nil {
+      return m, &IOError{Filename: filename, Op: "parse", Err: err}
+   }
+
+   return m, nil
+
 }
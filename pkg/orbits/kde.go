@@ -0,0 +1,195 @@
+package orbits
+
+import (
+   "math"
+
+   "gonum.org/v1/gonum/stat"
+   "gonum.org/v1/gonum/stat/distuv"
+)
+
+
+// weighted kernel-density estimate of the distribution of orbits in (log10(period),
+// eccentricity) with a Gaussian product kernel. Eccentricities are reflected at 0 and 1 so that
+// the density is not lost (nor biased) at the borders of [0, 1)
+type KDE struct {
+   LogPeriod []float64
+   Eccentricity []float64
+   Weight []float64
+   // bandwidths in log10(period) & eccentricity
+   HLogPeriod float64
+   HEccentricity float64
+   total float64
+}
+
+
+// create a kernel-density estimate from periods, eccentricities and their weights. bandwidth is
+// the rule to set the bandwidths: "scott" or "silverman" (which uses a robust estimate of the
+// spread of the data). Positive values of hLogPeriod & hEccentricity override the rule. Data
+// without spread (e.g. a single binary) and no bandwidth set is a GridError
+func NewKDE (period []float64, eccentricity []float64, weight []float64, bandwidth string, hLogPeriod float64, hEccentricity float64) (KDE, error) {
+
+   k := KDE{Eccentricity: eccentricity, Weight: weight}
+   k.LogPeriod = make([]float64, len(period))
+   for i, p := range period {
+      k.LogPeriod[i] = math.Log10(p)
+   }
+   for _, w := range weight {
+      k.total += w
+   }
+
+   // for a two dimensional kernel, Scott and Silverman factors are the same: n^(-1/6)
+   factor := math.Pow(EffectiveSampleSize(weight), -1.0/6.0)
+   k.HLogPeriod = factor * spread(k.LogPeriod, weight, bandwidth == "silverman")
+   k.HEccentricity = factor * spread(eccentricity, weight, bandwidth == "silverman")
+
+   if hLogPeriod > 0 {
+      k.HLogPeriod = hLogPeriod
+   }
+   if hEccentricity > 0 {
+      k.HEccentricity = hEccentricity
+   }
+
+   // kernels without width give densities that are NaN or infinite
+   if !(k.HLogPeriod > 0) {
+      return k, &GridError{Message: "kde bandwidth in log10(period) is 0, set kde_bandwidth_log_period"}
+   }
+   if !(k.HEccentricity > 0) {
+      return k, &GridError{Message: "kde bandwidth in eccentricity is 0, set kde_bandwidth_eccentricity"}
+   }
+
+   return k, nil
+
+}
+
+
+// weighted standard deviation of x or, when robust, the minimum between it and the
+// interquartile range divided by 1.349
+func spread (x []float64, weight []float64, robust bool) float64 {
+
+   sigma := stat.StdDev(x, weight)
+   if !robust {
+      return sigma
+   }
+
+   xSorted, wSorted := SortWithWeights(x, weight)
+   iqr := (WeightedQuantile(0.75, xSorted, wSorted) - WeightedQuantile(0.25, xSorted, wSorted)) / 1.349
+   if iqr > 0 && iqr < sigma {
+      return iqr
+   }
+
+   return sigma
+
+}
+
+
+// probability density per unit of log10(period) and of eccentricity
+func (k KDE) Density (period float64, eccentricity float64) float64 {
+
+   if k.total == 0 || eccentricity < 0 || eccentricity > 1 {
+      return 0
+   }
+
+   x := math.Log10(period)
+   density := 0.0
+   for i, w := range k.Weight {
+      dx := (x - k.LogPeriod[i]) / k.HLogPeriod
+      fx := math.Exp(-0.5 * dx * dx)
+      fy := 0.0
+      for _, y := range k.reflections(i) {
+         dy := (eccentricity - y) / k.HEccentricity
+         fy += math.Exp(-0.5 * dy * dy)
+      }
+      density += w * fx * fy
+   }
+
+   return density / (k.total * 2.0 * math.Pi * k.HLogPeriod * k.HEccentricity)
+
+}
+
+
+// probability of an orbit with period in [pMin, pMax) and eccentricity in [eMin, eMax)
+func (k KDE) Probability (pMin float64, pMax float64, eMin float64, eMax float64) float64 {
+
+   probabilities := k.Grid([]float64{pMin, pMax}, []float64{eMin, eMax})
+
+   return probabilities[0][0]
+
+}
+
+
+// probability of each cell of a grid with borders pBorders & eBorders. Rows are eccentricities
+// and columns periods (as in histogramOfOrbits)
+func (k KDE) Grid (pBorders []float64, eBorders []float64) [][]float64 {
+
+   nRows := len(eBorders) - 1
+   nCols := len(pBorders) - 1
+   probabilities := make([][]float64, nRows)
+   for i := 0; i < nRows; i++ {
+      probabilities[i] = make([]float64, nCols)
+   }
+   if k.total == 0 {
+      return probabilities
+   }
+
+   xBorders := make([]float64, len(pBorders))
+   for j, p := range pBorders {
+      xBorders[j] = math.Log10(p)
+   }
+
+   // the kernel is separable, so the mass of a cell is the product of the masses of its row and
+   // its column
+   xMass := make([]float64, nCols)
+   yMass := make([]float64, nRows)
+   for l, w := range k.Weight {
+      for j := 0; j < nCols; j++ {
+         xMass[j] = gaussianMass(xBorders[j], xBorders[j+1], k.LogPeriod[l], k.HLogPeriod)
+      }
+      for i := 0; i < nRows; i++ {
+         yMass[i] = 0
+         for _, y := range k.reflections(l) {
+            yMass[i] += gaussianMass(math.Max(0, eBorders[i]), math.Min(1, eBorders[i+1]), y, k.HEccentricity)
+         }
+      }
+      for i := 0; i < nRows; i++ {
+         if yMass[i] == 0 {
+            continue
+         }
+         for j := 0; j < nCols; j++ {
+            probabilities[i][j] += w / k.total * xMass[j] * yMass[i]
+         }
+      }
+   }
+
+   return probabilities
+
+}
+
+
+// eccentricity of a sample and its reflections at 0 and 1
+func (k KDE) reflections (i int) [3]float64 {
+
+   e := k.Eccentricity[i]
+
+   return [3]float64{e, -e, 2.0 - e}
+
+}
+
+
+// mass of a Gaussian with mean mu and dispersion h between a and b
+func gaussianMass (a float64, b float64, mu float64, h float64) float64 {
+
+   if b <= a {
+      return 0
+   }
+
+   return distuv.UnitNormal.CDF((b - mu) / h) - distuv.UnitNormal.CDF((a - mu) / h)
+
+}
+
+
+// kernel-density estimate of the bounded binaries
+func (b *Binary) OrbitsKDE () (KDE, error) {
+
+   return NewKDE(b.PeriodBounded, b.EccentricityBounded, b.WeightBounded, b.KDEBandwidth, b.KDEBandwidthLogPeriod, b.KDEBandwidthEccentricity)
+
+}
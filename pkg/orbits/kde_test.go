@@ -0,0 +1,63 @@
+package orbits
+
+import (
+   "context"
+   "math"
+   "testing"
+
+   "gonum.org/v1/gonum/floats"
+)
+
+
+// the kde of bounded binaries sums to 1 over every eccentricity and a wide range of periods, as
+// eccentricities are reflected at 0 and 1
+func TestKDEGridSumsToOne (t *testing.T) {
+
+   b := NewBinary(testConfig(20000))
+   err := b.Run(context.Background())
+   if err != nil {
+      t.Fatal(err)
+   }
+   k, err := b.OrbitsKDE()
+   if err != nil {
+      t.Fatal(err)
+   }
+
+   pMin, pMax := floats.Min(b.PeriodBounded), floats.Max(b.PeriodBounded)
+   pBorders, err := LogSpace(math.Log10(pMin) - 10 * k.HLogPeriod, math.Log10(pMax) + 10 * k.HLogPeriod, 30, 10.0)
+   if err != nil {
+      t.Fatal(err)
+   }
+   eBorders, err := LinSpace(0, 1, 10)
+   if err != nil {
+      t.Fatal(err)
+   }
+
+   total := 0.0
+   for _, row := range k.Grid(pBorders, eBorders) {
+      total += floats.Sum(row)
+   }
+   if math.Abs(total - 1) > 1e-6 {
+      t.Errorf("kde grid sums to %f, want 1", total)
+   }
+
+}
+
+
+// a single bounded binary has no spread: its bandwidths must be set, or the kde is a GridError
+func TestKDESingleBinary (t *testing.T) {
+
+   _, err := NewKDE([]float64{1e6}, []float64{0.3}, []float64{1}, "scott", 0, 0)
+   if _, ok := err.(*GridError); !ok {
+      t.Errorf("single binary: got error %v, want a GridError", err)
+   }
+
+   k, err := NewKDE([]float64{1e6}, []float64{0.3}, []float64{1}, "scott", 0.1, 0.05)
+   if err != nil {
+      t.Fatal(err)
+   }
+   if d := k.Density(1e6, 0.3); math.IsNaN(d) || math.IsInf(d, 0) || d <= 0 {
+      t.Errorf("density at the binary: got %f, want a positive value", d)
+   }
+
+}
@@ -0,0 +1,191 @@
+package orbits
+
+import (
+   "math"
+   "strings"
+
+   "github.com/asimazbunzel/go-orbits/pkg/io"
+   "github.com/asimazbunzel/go-orbits/pkg/units"
+
+   "gonum.org/v1/gonum/floats"
+)
+
+
+// axis of a multi-dimensional grid: a post-SN quantity of bounded binaries, the scale of its
+// bins ("linear" or "log"), their number and the quantiles that limit it
+type GridAxis struct {
+   Quantity string `yaml:"quantity" json:"quantity"`
+   Scale string `yaml:"scale" json:"scale"`
+   Bins int `yaml:"bins" json:"bins"`
+   QuantileMin float64 `yaml:"quantile_min" json:"quantile_min"`
+   QuantileMax float64 `yaml:"quantile_max" json:"quantile_max"`
+}
+
+
+// quantities of bounded binaries that can be used as axes of a grid
+var gridQuantities = []string{"separation", "period", "eccentricity", "vsys", "tilt", "tgw"}
+
+
+// values of a quantity for each bounded binary, nil if unknown
+func (b *Binary) boundedQuantity (quantity string) []float64 {
+
+   switch quantity {
+   case "separation":
+      return b.SeparationBounded
+   case "period":
+      return b.PeriodBounded
+   case "eccentricity":
+      return b.EccentricityBounded
+   case "vsys":
+      return b.VsysBounded
+   case "tilt":
+      return b.TiltBounded
+   case "tgw":
+      return b.TimeGWBounded
+   }
+
+   return nil
+
+}
+
+
+// factor to go from CGS to astro units (Rsun, days, km/s, yr) for each quantity
+func astroFactor (quantity string) float64 {
+
+   switch quantity {
+   case "separation":
+      return float64(units.SolarRadius)
+   case "period":
+      return float64(units.Day)
+   case "vsys":
+      return float64(units.KilometerPerSecond)
+   case "tgw":
+      return float64(units.Year)
+   }
+
+   return 1
+
+}
+
+
+// astro unit of each quantity
+func astroUnit (quantity string) string {
+
+   switch quantity {
+   case "separation":
+      return "Rsun"
+   case "period":
+      return "d"
+   case "vsys":
+      return "km/s"
+   case "tilt":
+      return "rad"
+   case "tgw":
+      return "yr"
+   }
+
+   return ""
+
+}
+
+
+// N-dimensional histogram of bounded binaries with the axes of GridAxes, together with the 1D
+// histogram of each axis (marginals). Probabilities are stored in row-major order (last axis
+// changes fastest)
+func (b *Binary) MultiGridOfOrbits () error {
+
+   if b.LogLevel != "none" {
+      io.LogInfo("ORBITS - multigrid.go - MultiGridOfOrbits", "calculating multi-dimensional grid of orbits")
+   }
+
+   if len(b.IndexBounded) == 0 || len(b.GridAxes) == 0 {
+      return &GridError{Message: "no bounded binaries or no axes to make a multi-dimensional grid"}
+   }
+
+   // borders & bin of each binary for every axis
+   nAxes := len(b.GridAxes)
+   b.MultiGridEdges = make([][]float64, nAxes)
+   bins := make([][]int, nAxes)
+   for d, axis := range b.GridAxes {
+      values := b.boundedQuantity(axis.Quantity)
+      if values == nil {
+         return &ConfigError{Option: "grid_axes", Message: "unknown grid quantity: " + axis.Quantity + ", options are: " + strings.Join(gridQuantities, ", ")}
+      }
+      if axis.Bins <= 0 {
+         return &ConfigError{Option: "grid_axes", Message: "number of bins must be greater than 0 for: " + axis.Quantity}
+      }
+
+      x, xWeight := SortWithWeights(values, b.WeightBounded)
+      xMin := WeightedQuantile(axis.QuantileMin, x, xWeight)
+      xMax := WeightedQuantile(axis.QuantileMax, x, xWeight)
+
+      var err error
+      if axis.Scale == "log" {
+         if xMin <= 0 {
+            return &GridError{Message: "log scale needs positive values for: " + axis.Quantity}
+         }
+         b.MultiGridEdges[d], err = LogSpace(math.Log10(xMin), math.Log10(xMax), axis.Bins + 1, 10.0)
+      } else if axis.Scale == "linear" || axis.Scale == "" {
+         b.MultiGridEdges[d], err = LinSpace(xMin, xMax, axis.Bins + 1)
+      } else {
+         return &ConfigError{Option: "grid_axes", Message: "unknown scale: " + axis.Scale + ", options are: linear, log"}
+      }
+      if err != nil {
+         return err
+      }
+
+      bins[d] = make([]int, len(values))
+      for k, v := range values {
+         bins[d][k] = cellIndex(v, b.MultiGridEdges[d])
+      }
+   }
+
+   // number of cells & strides of the flattened grid
+   strides := make([]int, nAxes)
+   nCells := 1
+   for d := nAxes-1; d >= 0; d-- {
+      strides[d] = nCells
+      nCells *= b.GridAxes[d].Bins
+   }
+
+   totalWeight := floats.Sum(b.WeightBounded)
+   b.MultiGridProbability = make([]float64, nCells)
+   b.MultiGridMarginals = make([][]float64, nAxes)
+   for d, axis := range b.GridAxes {
+      b.MultiGridMarginals[d] = make([]float64, axis.Bins)
+   }
+
+   for k, _ := range b.IndexBounded {
+      w := b.WeightBounded[k] / totalWeight
+      cell := 0
+      inside := true
+      for d := 0; d < nAxes; d++ {
+         if bins[d][k] < 0 {
+            inside = false
+            continue
+         }
+         b.MultiGridMarginals[d][bins[d][k]] += w
+         cell += bins[d][k] * strides[d]
+      }
+      if inside {
+         b.MultiGridProbability[cell] += w
+      }
+   }
+
+   return nil
+
+}
+
+
+// bin of each axis of a cell of the multi-dimensional grid
+func (b *Binary) multiGridBins (cell int) []int {
+
+   index := make([]int, len(b.GridAxes))
+   for d := len(b.GridAxes)-1; d >= 0; d-- {
+      index[d] = cell % b.GridAxes[d].Bins
+      cell /= b.GridAxes[d].Bins
+   }
+
+   return index
+
+}
@@ -0,0 +1,73 @@
+package orbits
+
+import (
+   "context"
+   "math"
+   "testing"
+
+   "gonum.org/v1/gonum/floats"
+)
+
+
+// cells of a multi-dimensional grid in (period, eccentricity) are those of the grid of orbits
+// with the same edges, and its marginals are the sums of those cells plus the binaries outside
+// of the other axis
+func TestMultiGridMarginals (t *testing.T) {
+
+   cfg := testConfig(20000)
+   cfg.StoreMultiGrid = true
+   cfg.GridAxes = []GridAxis{
+      {Quantity: "period", Scale: "log", Bins: 8, QuantileMin: 0.05, QuantileMax: 0.95},
+      {Quantity: "eccentricity", Scale: "linear", Bins: 5, QuantileMin: 0.1, QuantileMax: 0.9},
+   }
+   b := NewBinary(cfg)
+   err := b.Run(context.Background())
+   if err != nil {
+      t.Fatal(err)
+   }
+
+   // binaries inside the edges of one axis but not of the other
+   pEdges, eEdges := copyFloats(b.MultiGridEdges[0]), copyFloats(b.MultiGridEdges[1])
+   outsideE := make([]float64, len(pEdges)-1)
+   outsideP := make([]float64, len(eEdges)-1)
+   total := floats.Sum(b.WeightBounded)
+   for k, p := range b.PeriodBounded {
+      i, j := cellIndex(b.EccentricityBounded[k], eEdges), cellIndex(p, pEdges)
+      if j >= 0 && i < 0 {
+         outsideE[j] += b.WeightBounded[k] / total
+      }
+      if i >= 0 && j < 0 {
+         outsideP[i] += b.WeightBounded[k] / total
+      }
+   }
+
+   // grid of orbits of the same kicks, with the edges of the multi-dimensional grid (periods in
+   // days)
+   cfg.StoreMultiGrid = false
+   cfg.PeriodEdges, cfg.EccentricityEdges = pEdges, eEdges
+   grid, err := Run(context.Background(), cfg)
+   if err != nil {
+      t.Fatal(err)
+   }
+
+   for i, row := range grid.ProbabilityMatrix {
+      for j, p := range row {
+         if cell := b.MultiGridProbability[j * len(grid.ProbabilityMatrix) + i]; math.Abs(cell - p) > 1e-12 {
+            t.Errorf("cell (%d, %d): got %f in the multi-dimensional grid, want %f", j, i, cell, p)
+         }
+      }
+      if m := b.MultiGridMarginals[1][i]; math.Abs(m - floats.Sum(row) - outsideP[i]) > 1e-12 {
+         t.Errorf("eccentricity marginal %d: got %f, want %f", i, m, floats.Sum(row) + outsideP[i])
+      }
+   }
+   for j, _ := range grid.ProbabilityMatrix[0] {
+      column := 0.0
+      for i, _ := range grid.ProbabilityMatrix {
+         column += grid.ProbabilityMatrix[i][j]
+      }
+      if m := b.MultiGridMarginals[0][j]; math.Abs(m - column - outsideE[j]) > 1e-12 {
+         t.Errorf("period marginal %d: got %f, want %f", j, m, column + outsideE[j])
+      }
+   }
+
+}
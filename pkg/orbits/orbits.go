@@ -3,157 +3,318 @@ package orbits
 import (
 	"fmt"
 	"github.com/asimazbunzel/go-orbits/pkg/io"
+	"github.com/asimazbunzel/go-orbits/pkg/units"
 	"math"
 	"sort"
 	"strconv"
 
 	"golang.org/x/exp/rand"
-	"gonum.org/v1/gonum/stat"
+	"gonum.org/v1/gonum/floats"
 	"gonum.org/v1/gonum/stat/distuv"
 )
 
-// structure with binary configuration
+// structure with binary configuration (its options) and the results of the study of its kicks.
+// Results are stored in parallel slices, one element per kick, bounded binary or cell of a grid
 type Binary struct {
-   M1 float64 `yaml:"m1"`
-   M2 float64 `yaml:"m2"`
-   Separation float64 `yaml:"separation"`
-   Period float64 `yaml:"period"`
-   
-   MCO float64 `yaml:"compact_object_mass"`
-
-   KickStrengthDistribution string `yaml:"kick_distribution"`
-   KickDirection string `yaml:"kick_direction"`
-
-   ReduceByFallback bool `yaml:"reduce_by_fallback"`
-   FallbackFraction float64 `yaml:"fallback_fraction"`
-
-   SigmaStrength float64 `yaml:"kick_sigma"`
-   MinKickStrength float64 `yaml:"min_kick_value"`
-   MaxKickStrength float64 `yaml:"max_kick_value"`
-
-   MinPhi float64 `yaml:"min_phi"`
-   MaxPhi float64 `yaml:"max_phi"`
-
-   MinTheta float64 `yaml:"min_theta"`
-   MaxTheta float64 `yaml:"max_theta"`
-   
-   Seed uint64 `yaml:"seed"`
-
-   NumberOfCases int `yaml:"number_of_cases"`
-   
-   LogLevel string `yaml:"log_level"`
-
-   StoreKicks bool `yaml:"save_kicks"`
-   StoreOrbits bool `yaml:"save_bounded_orbits"`
-   StoreGrid bool `yaml:"save_grid_of_orbits"`
-
-   KicksFilename string `yaml:"kicks_filename"`
-   BoundedBinariesFilename string `yaml:"bounded_orbits_filename"`
-   GridFilename string `yaml:"grid_of_orbits_filename"`
-
-   PQuantileMin float64 `yaml:"period_quantile_min"`
-   PQuantileMax float64 `yaml:"period_quantile_max"`
-   EQuantileMin float64 `yaml:"eccentricity_quantile_min"`
-   EQuantileMax float64 `yaml:"eccentricity_quantile_max"`
-   PNum int `yaml:"number_of_periods"`
-   ENum int `yaml:"number_of_eccentricities"`
-   MinProb float64 `yaml:"minimum_probability_for_grid"`
+   Config
+
+
+   ConfigFile string
+   Timestamp string
+   ConfigHash string
+
+   // whether options & results are in CGS units (otherwise astro units)
+   cgs bool
+
+   // index of the first kick in W, only different from zero for batches of streaming runs
+   firstKick int
+   // running totals of a streaming run, whose kicks & orbits are not kept (nil otherwise)
+   totals *streamTotals
+   // checkpoint from where a streaming run goes on (nil otherwise)
+   resume *checkpoint
 
    W []float64
    Phi []float64
    Theta []float64
+   Weight []float64
 
    IndexBounded []int
    WBounded []float64
    ThetaBounded []float64
    PhiBounded []float64
+   WeightBounded []float64
    SeparationBounded []float64
    EccentricityBounded []float64
    PeriodBounded []float64
+   VsysBounded []float64
+   TiltBounded []float64
+   TimeGWBounded []float64
 
    PeriodGrid []float64
    SeparationGrid []float64
    EccentricityGrid []float64
+   PeriodLowerGrid []float64
+   PeriodUpperGrid []float64
+   EccentricityLowerGrid []float64
+   EccentricityUpperGrid []float64
    ProbabilityGrid []float64
+   ProbabilityLowerGrid []float64
+   ProbabilityUpperGrid []float64
+   PeriodBordersGrid []float64
+   EccentricityBordersGrid []float64
+   ProbabilityMatrix [][]float64
+   ProbabilityLowerMatrix [][]float64
+   ProbabilityUpperMatrix [][]float64
+   ProbabilityOutsideGrid float64
+   ProbabilityBelowThreshold float64
+
+   MultiGridEdges [][]float64
+   MultiGridProbability []float64
+   MultiGridMarginals [][]float64
+
+   PeriodRepresentative []float64
+   SeparationRepresentative []float64
+   EccentricityRepresentative []float64
+   VsysRepresentative []float64
+   ProbabilityRepresentative []float64
+
+   KickThetaMap SurvivalMap
+   ThetaPhiMap SurvivalMap
+   ThetaBoundary []float64
+   MinKickBoundary []float64
+   MaxKickBoundary []float64
+
+   AchievedError float64
+   BoundedFractionWilson [2]float64
+   BoundedFractionClopperPearson [2]float64
 
 }
 
 
 // initialize structure Binary with the info from a binary system that will then be analyze in
 // different conditions due to asymmetric momentum kicks
-// it returns the Binary object
-func InitBinary (filename string) Binary {
+// it returns the Binary object, or an error if the config file cannot be read or parsed
+func InitBinary (filename string) (Binary, error) {
 
-   // load binary into memory
-   var binary Binary
-   err := binary.parseYAML(filename)
+   // load configuration, with default values for options that might be missing
+   cfg, err := LoadConfig(filename)
    if err != nil {
-      io.LogError("ORBITS - orbits.go - InitBinary", "unable to parse YAML file at start")
+      return Binary{Config: cfg}, err
    }
+   binary := NewBinary(cfg)
+   binary.ConfigFile = filename
+
+   return binary, nil
+}
+
+
+// new Binary, in astro units and without results, working on its own copy of cfg
+func NewBinary (cfg Config) Binary {
+
+   binary := Binary{Config: cfg.clone()}
+   binary.initProvenance()
 
    return binary
+
+}
+
+
+// remove every result (kicks included), keeping options & provenance
+func (b *Binary) Reset () {
+
+   *b = Binary{Config: b.Config, ConfigFile: b.ConfigFile, Timestamp: b.Timestamp, ConfigHash: b.ConfigHash, cgs: b.cgs}
+
+}
+
+
+// remove every result but kicks
+func (b *Binary) resetOrbits () {
+
+   w, theta, phi, weight := b.W, b.Theta, b.Phi, b.Weight
+   b.Reset()
+   b.W, b.Theta, b.Phi, b.Weight = w, theta, phi, weight
+
+}
+
+
+// remove the grid of orbits
+func (b *Binary) resetGrid () {
+
+   b.PeriodGrid, b.SeparationGrid, b.EccentricityGrid = nil, nil, nil
+   b.PeriodLowerGrid, b.PeriodUpperGrid = nil, nil
+   b.EccentricityLowerGrid, b.EccentricityUpperGrid = nil, nil
+   b.ProbabilityGrid, b.ProbabilityLowerGrid, b.ProbabilityUpperGrid = nil, nil, nil
+   b.PeriodBordersGrid, b.EccentricityBordersGrid = nil, nil
+   b.ProbabilityMatrix, b.ProbabilityLowerMatrix, b.ProbabilityUpperMatrix = nil, nil, nil
+   b.ProbabilityOutsideGrid, b.ProbabilityBelowThreshold = 0, 0
+
 }
 
 
 // create slices of asymmetric kicks following a given probability density function
-func (b *Binary) ComputeKicks () {
+// kicks (and every result) of previous calls are replaced
+func (b *Binary) ComputeKicks () error {
 
    if b.LogLevel != "none" {
       io.LogInfo("ORBITS - orbits.go - ComputeKicks", "computing momentum kicks")
    }
 
-   // random seed
-   src := rand.New(rand.NewSource(b.Seed))
+   b.Reset()
+
+   var err error
+   if b.Sampling == "quadrature" {
+      // deterministic grid of kicks, NumberOfCases is set by the number of nodes
+      err = b.quadratureKicks()
+   } else {
+      err = b.drawKicks(b.NumberOfCases)
+   }
+   if err != nil {
+      return err
+   }
+
+   // kicks are made in km/s
+   if b.cgs {
+      floats.Scale(float64(units.KilometerPerSecond), b.W)
+   }
+
+   if b.LogLevel == "debug" {
+      last_index := 0
+      for k, _ := range b.PeriodGrid {
+         last_index = k
+      }
+      digits := CountDigits(last_index)
+      fmt.Printf("  id      w   theta   phi   weight\n")
+      for k := 0; k < b.NumberOfCases; k++ {
+         fmt.Printf("  %0*d    %.2E     %.2E       %.2E       %.2E\n", digits, k, b.W[k], b.Theta[k], b.Phi[k], b.Weight[k])
+      }
+   }
+
+   return nil
+
+}
+
+
+// distributions from where kicks are drawn. With importance sampling, these are the proposal
+// distributions and each kick gets a weight to recover the ones of the config
+type kickSampler struct {
+   strength string
+   sigma float64
+   wMin float64
+   wMax float64
+   direction string
+}
+
+
+// distributions of kicks of the config, checked before drawing anything
+func (b *Binary) kickSampler () (kickSampler, error) {
+
+   s := kickSampler{strength: b.KickStrengthDistribution, sigma: b.SigmaStrength, wMin: b.MinKickStrength, wMax: b.MaxKickStrength, direction: b.KickDirection}
+   strengthOption, directionOption := "kick_distribution", "kick_direction"
+   directions := []string{"Uniform"}
+   if b.Sampling == "importance" {
+      s = kickSampler{strength: b.ProposalKickStrengthDistribution, sigma: b.ProposalSigmaStrength, wMin: b.ProposalMinKickStrength, wMax: b.ProposalMaxKickStrength, direction: b.ProposalKickDirection}
+      strengthOption, directionOption = "proposal_kick_distribution", "proposal_kick_direction"
+      directions = append(directions, "Backward")
+   } else if b.Sampling != "" && b.Sampling != "random" {
+      return s, &DistributionError{Option: "sampling", Value: b.Sampling, Options: []string{"random", "importance", "quadrature"}}
+   }
+
+   if s.strength != "Maxwell" && s.strength != "Uniform" {
+      return s, &DistributionError{Option: strengthOption, Value: s.strength, Options: []string{"Maxwell", "Uniform"}}
+   }
+   if s.direction != "Uniform" && !(s.direction == "Backward" && b.Sampling == "importance") {
+      return s, &DistributionError{Option: directionOption, Value: s.direction, Options: directions}
+   }
+
+   return s, nil
+
+}
+
+
+// append n kicks, in km/s, drawn in parallel in chunks of kickChunk kicks. Each chunk draws from
+// its own random stream, set by the seed and the index of its first kick, so kicks are the same
+// whatever the number of workers
+func (b *Binary) drawKicks (n int) error {
+
+   s, err := b.kickSampler()
+   if err != nil {
+      return err
+   }
+
+   first := b.firstKick + len(b.W)
+   parts := make([]Binary, numberOfChunks(n))
+   forEachChunk(first, first + n, b.workers(), func (c int, lo int, hi int) {
+      parts[c] = Binary{Config: b.Config}
+      src := rand.New(rand.NewSource(chunkSeed(b.Seed, lo)))
+      parts[c].drawChunk(src, hi - lo, s)
+   })
+
+   for _, p := range parts {
+      b.W = append(b.W, p.W...)
+      b.Theta = append(b.Theta, p.Theta...)
+      b.Phi = append(b.Phi, p.Phi...)
+      b.Weight = append(b.Weight, p.Weight...)
+   }
+
+   return nil
+
+}
+
+
+// append n kicks, in km/s, drawn from the distributions of s with the random numbers of src
+func (b *Binary) drawChunk (src *rand.Rand, n int, s kickSampler) {
+
+   first := len(b.W)
 
    // Strength of kick based on config option
-   if b.KickStrengthDistribution == "Maxwell" {
+   if s.strength == "Maxwell" {
       // Maxwell distribution is just a chi-squared distribution with 3 d.o.f., k=3
       // therefore, just use inverse sampling for the chi-squared and then correct values with
       // normalization constant
-      maxwell := distuv.ChiSquared{3, src}
-      for k := 0; k < b.NumberOfCases; k++ {
-         wTmp := b.SigmaStrength * math.Sqrt(maxwell.Rand())
+      maxwell := distuv.ChiSquared{K: 3, Src: src}
+      for k := 0; k < n; k++ {
+         wTmp := s.sigma * math.Sqrt(maxwell.Rand())
          if b.ReduceByFallback { wTmp *= (1.0 - b.FallbackFraction) }
          b.W = append(b.W, wTmp)
       }
-   } else if b.KickStrengthDistribution == "Uniform" {
+   } else {
       // Uniform distribution needs min & max values as input
-      uniform := distuv.Uniform{b.MinKickStrength, b.MaxKickStrength, src}
-      for k := 0; k < b.NumberOfCases; k++ {
+      uniform := distuv.Uniform{Min: s.wMin, Max: s.wMax, Src: src}
+      for k := 0; k < n; k++ {
          wTmp := uniform.Rand()
          if b.ReduceByFallback { wTmp *= (1.0 - b.FallbackFraction) }
          b.W = append(b.W, wTmp)
       }
-   } else {
-      io.LogError("ORBITS - orbits.go - ComputeKicks", "unknown KickStrengthDistribution")
    }
 
    // Direction of kicks
-   if b.KickDirection == "Uniform" {
+   {
       // phi distribution must be between 0 and 2pi
-      uniform_phi := distuv.Uniform{b.MinPhi * math.Pi, b.MaxPhi * math.Pi, src}
-      for k := 0; k < b.NumberOfCases; k++ {
+      uniform_phi := distuv.Uniform{Min: b.MinPhi * math.Pi, Max: b.MaxPhi * math.Pi, Src: src}
+      for k := 0; k < n; k++ {
          b.Phi = append(b.Phi, uniform_phi.Rand())
       }
 
-      // theta distribution must be between 0 and pi, but remember that is modulated by cosine
-      uniform_theta := distuv.Uniform{0, 1, src}
-      for k := 0; k < b.NumberOfCases; k++ {
-         b.Theta = append(b.Theta, math.Acos(2.0 * uniform_theta.Rand() - 1.0))
+      // theta distribution must be between 0 and pi, but remember that is modulated by cosine.
+      // Backward kicks (only as a proposal) favour cos(theta) close to -1
+      uniform_theta := distuv.Uniform{Min: 0, Max: 1, Src: src}
+      for k := 0; k < n; k++ {
+         if s.direction == "Backward" {
+            b.Theta = append(b.Theta, math.Acos(backwardRand(uniform_theta.Rand(), b.ProposalBackwardBias)))
+         } else {
+            b.Theta = append(b.Theta, math.Acos(2.0 * uniform_theta.Rand() - 1.0))
+         }
       }
-   } else {
-      io.LogError("ORBITS - orbits.go - ComputeKicks", "unknown KickDirection")
    }
 
-   if b.LogLevel == "debug" {
-      last_index := 0
-      for k, _ := range b.PeriodGrid {
-         last_index = k
-      }
-      digits := CountDigits(last_index)
-      fmt.Printf("  id      w   theta   phi\n")
-      for k := 0; k < b.NumberOfCases; k++ {
-         fmt.Printf("  %0*d    %.2E     %.2E       %.2E\n", digits, k, b.W[k], b.Theta[k], b.Phi[k])
+   // weight of each kick, only different from unity when using importance sampling
+   for k := first; k < len(b.W); k++ {
+      if b.Sampling == "importance" {
+         w := b.W[k]
+         if b.ReduceByFallback { w /= (1.0 - b.FallbackFraction) }
+         b.Weight = append(b.Weight, b.importanceWeight(w, b.Theta[k]))
+      } else {
+         b.Weight = append(b.Weight, 1.0)
       }
    }
 
@@ -169,135 +330,429 @@ func (b *Binary) OrbitsAfterKicks () {
       io.LogInfo("ORBITS - orbits.go - OrbitAfterKicks", msg)
    }
 
+   b.resetOrbits()
+   b.solveOrbits(0, b.NumberOfCases)
+   b.boundedFractionIntervals()
+
+   if b.LogLevel == "info" || b.LogLevel == "debug" {
+      b.printSummary()
+   }
+
+}
+
+
+// solve post core-collapse orbits for kicks with index between first (included) and last
+// (excluded), in parallel chunks of kickChunk kicks. Bounded binaries are appended in the order of
+// their kicks. Must be called in CGS units
+func (b *Binary) solveOrbits (first int, last int) {
+
+   parts := make([]Binary, numberOfChunks(last - first))
+   forEachChunk(first, last, b.workers(), func (c int, lo int, hi int) {
+      parts[c] = Binary{Config: b.Config, firstKick: b.firstKick, W: b.W, Theta: b.Theta, Phi: b.Phi, Weight: b.Weight}
+      parts[c].solveChunk(lo, hi)
+   })
+
+   for _, p := range parts {
+      b.IndexBounded = append(b.IndexBounded, p.IndexBounded...)
+      b.WBounded = append(b.WBounded, p.WBounded...)
+      b.ThetaBounded = append(b.ThetaBounded, p.ThetaBounded...)
+      b.PhiBounded = append(b.PhiBounded, p.PhiBounded...)
+      b.WeightBounded = append(b.WeightBounded, p.WeightBounded...)
+      b.SeparationBounded = append(b.SeparationBounded, p.SeparationBounded...)
+      b.EccentricityBounded = append(b.EccentricityBounded, p.EccentricityBounded...)
+      b.PeriodBounded = append(b.PeriodBounded, p.PeriodBounded...)
+      b.VsysBounded = append(b.VsysBounded, p.VsysBounded...)
+      b.TiltBounded = append(b.TiltBounded, p.TiltBounded...)
+      b.TimeGWBounded = append(b.TimeGWBounded, p.TimeGWBounded...)
+   }
+
+}
+
+
+// solve orbits for kicks with index between first (included) and last (excluded), appending
+// bounded binaries to the slices of b
+func (b *Binary) solveChunk (first int, last int) {
+
+   // masses after the SN (compact object & companion) and separation pre-SN, in CGS
+   mCO, m2 := b.massCO(), b.mass2()
+   mPost := float64(mCO + m2)
+   a := float64(b.separation())
+
    // velocity pre-SN
-   vPre := math.Sqrt(StandardCgrav * (b.M1 + b.M2) / b.Separation)
+   vPre := float64(OrbitalVelocity(b.separation(), b.mass1(), m2))
 
-   for k := 0; k < b.NumberOfCases; k++ {
+   for k := first; k < last; k++ {
 
       // kick velocity projected to (x,y,z)
-      // wx := b.W[k] * math.Cos(b.Phi[k]) * math.Sin(b.Theta[k])
+      wx := b.W[k] * math.Cos(b.Phi[k]) * math.Sin(b.Theta[k])
       wy := b.W[k] * math.Cos(b.Theta[k])
       wz := b.W[k] * math.Sin(b.Phi[k]) * math.Sin(b.Theta[k])
 
       // eqs (3), (4) & (5)
-      apost := StandardCgrav * (b.MCO + b.M2) / (2.0 * StandardCgrav * (b.MCO + b.M2) / b.Separation - math.Pow(b.W[k],2.0) - math.Pow(vPre,2.0) - 2.0 * wy * vPre)
-      epost := math.Sqrt(1.0 - (math.Pow(wz,2.0) + math.Pow(wy,2.0) + math.Pow(vPre,2.0) + 2.0 * wy * vPre) * math.Pow(b.Separation,2.0) / (StandardCgrav * (b.MCO + b.M2) * apost))
+      apost := units.G * mPost / (2.0 * units.G * mPost / a - math.Pow(b.W[k],2.0) - math.Pow(vPre,2.0) - 2.0 * wy * vPre)
+      epost := math.Sqrt(1.0 - (math.Pow(wz,2.0) + math.Pow(wy,2.0) + math.Pow(vPre,2.0) + 2.0 * wy * vPre) * math.Pow(a,2.0) / (units.G * mPost * apost))
 
       if epost < 0 || epost > 1 {
          if b.LogLevel == "debug" {
-            fmt.Printf("unbounded binary for case: id=%d, w=%.2E, theta=%.2f, phi=%.2f, a=%.2E, e=%.2f\n", k, b.W[k]/1e5, b.Theta[k], b.Phi[k], apost/Rsun, epost)
+            fmt.Printf("unbounded binary for case: id=%d, w=%.2E, theta=%.2f, phi=%.2f, a=%.2E, e=%.2f\n", b.firstKick + k, units.Velocity(b.W[k]).In(units.KilometerPerSecond), b.Theta[k], b.Phi[k], units.Length(apost).In(units.SolarRadius), epost)
          }
       } else {
 
-         b.IndexBounded = append(b.IndexBounded, k)
+         b.IndexBounded = append(b.IndexBounded, b.firstKick + k)
          b.WBounded = append(b.WBounded, b.W[k])
          b.ThetaBounded = append(b.ThetaBounded , b.Theta[k])
          b.PhiBounded = append(b.PhiBounded, b.Phi[k])
+         b.WeightBounded = append(b.WeightBounded, b.Weight[k])
 
          b.SeparationBounded = append(b.SeparationBounded, apost)
          b.EccentricityBounded = append(b.EccentricityBounded, epost)
-         // kepler needed here
-         b.PeriodBounded = append(b.PeriodBounded, AtoP(apost, b.M1, b.M2))
+         // kepler needed here, with the masses after the SN
+         ppost := AtoP(units.Length(apost), mCO, m2)
+         b.PeriodBounded = append(b.PeriodBounded, float64(ppost))
+         b.VsysBounded = append(b.VsysBounded, b.systemicVelocity(vPre, wx, wy, wz))
+         // angle between orbital angular momentum before & after the kick
+         b.TiltBounded = append(b.TiltBounded, math.Acos((vPre + wy) / math.Sqrt(math.Pow(vPre + wy,2.0) + math.Pow(wz,2.0))))
+         b.TimeGWBounded = append(b.TimeGWBounded, float64(MergerTime(units.Length(apost), epost, mCO, m2)))
 
          // if here, binary is bounded after momentum kick
          if b.LogLevel == "debug" {
-            fmt.Printf("  bounded binary for case: id=%d, w=%.2E, theta=%.2f, phi=%.2f, a=%.2E, p=%.2E, e=%.2f\n", k, b.W[k]/1e5, b.Theta[k], b.Phi[k], apost/Rsun, AtoP(apost, b.M1, b.M2)/24.0/3600.0, epost)
+            fmt.Printf("  bounded binary for case: id=%d, w=%.2E, theta=%.2f, phi=%.2f, a=%.2E, p=%.2E, e=%.2f\n", b.firstKick + k, units.Velocity(b.W[k]).In(units.KilometerPerSecond), b.Theta[k], b.Phi[k], units.Length(apost).In(units.SolarRadius), ppost.In(units.Day), epost)
          }
       }
    }
 
+}
 
-   if b.LogLevel == "info" || b.LogLevel == "debug" {
-      nbounded := len(b.IndexBounded)
-      nunbounded := b.NumberOfCases - len(b.IndexBounded)
-      fmt.Println("\nSummary of momentum kicks:")
-      fmt.Println("number of kicks:", b.NumberOfCases)
-      fmt.Printf("fraction of binaries bounded: %d/%d (%f%%)\n", nbounded, b.NumberOfCases, 100*float64(nbounded)/float64(b.NumberOfCases))
-      fmt.Printf("fraction of binaries unbounded: %d/%d (%f%%)\n\n", nunbounded, b.NumberOfCases, 100*float64(nunbounded)/float64(b.NumberOfCases))
+
+// systemic velocity of the binary just after the kick, from the change of momentum of the
+// exploding star (in the frame of the center of mass previous to the kick)
+func (b *Binary) systemicVelocity (vPre float64, wx float64, wy float64, wz float64) float64 {
+
+   m1, m2, mCO := float64(b.mass1()), float64(b.mass2()), float64(b.massCO())
+   mPost := mCO + m2
+   vx := mCO * wx / mPost
+   vy := (m2 * (mCO - m1) / (m1 + m2) * vPre + mCO * wy) / mPost
+   vz := mCO * wz / mPost
+
+   return math.Sqrt(math.Pow(vx,2.0) + math.Pow(vy,2.0) + math.Pow(vz,2.0))
+
+}
+
+
+// summary of momentum kicks to terminal
+func (b *Binary) printSummary () {
+
+   nkicks := b.numberOfKicks()
+   nbounded := b.numberOfBounded()
+   nunbounded := nkicks - nbounded
+   fBounded := b.BoundedFraction()
+   fmt.Println("\nSummary of momentum kicks:")
+   fmt.Println("number of kicks:", nkicks)
+   fmt.Printf("fraction of binaries bounded: %d/%d (%f%%)\n", nbounded, nkicks, 100*fBounded)
+   fmt.Printf("fraction of binaries unbounded: %d/%d (%f%%)\n", nunbounded, nkicks, 100*(1-fBounded))
+   fmt.Printf("%g%% Wilson interval of bounded fraction: [%f%%, %f%%]\n", 100*b.ConfidenceLevel, 100*b.BoundedFractionWilson[0], 100*b.BoundedFractionWilson[1])
+   fmt.Printf("%g%% Clopper-Pearson interval of bounded fraction: [%f%%, %f%%]\n", 100*b.ConfidenceLevel, 100*b.BoundedFractionClopperPearson[0], 100*b.BoundedFractionClopperPearson[1])
+   if b.Sampling == "importance" {
+      fmt.Printf("effective number of kicks: %.1f\n", b.effectiveNumberOfKicks())
+      fmt.Printf("effective number of bounded binaries: %.1f\n", b.effectiveNumberOfBounded())
+   }
+   fmt.Printf("\n")
+
+   // kicks of streaming runs are not kept, there is nothing to plot
+   if b.TerminalPlots && b.totals == nil {
+      b.printSparklines()
+   }
+
+}
+
+
+// number of kicks, either kept in memory or streamed
+func (b *Binary) numberOfKicks () int {
+
+   if b.totals != nil {
+      return b.totals.kicks
+   }
+
+   return len(b.W)
+
+}
+
+
+// number of binaries bounded after their kick, either kept in memory or streamed
+func (b *Binary) numberOfBounded () int {
+
+   if b.totals != nil {
+      return b.totals.bounded
+   }
+
+   return len(b.IndexBounded)
+
+}
+
+
+// sums of weights and of squared weights of all kicks and of those that leave the binary bounded
+func (b *Binary) weightSums () (float64, float64, float64, float64) {
+
+   if b.totals != nil {
+      return b.totals.weight, b.totals.weight2, b.totals.weightBounded, b.totals.weightBounded2
+   }
+
+   return floats.Sum(b.Weight), floats.Dot(b.Weight, b.Weight), floats.Sum(b.WeightBounded), floats.Dot(b.WeightBounded, b.WeightBounded)
+
+}
+
+
+// effective number of kicks, given their weights
+func (b *Binary) effectiveNumberOfKicks () float64 {
+
+   if b.totals != nil {
+      return effectiveSize(b.totals.weight, b.totals.weight2)
+   }
+
+   return EffectiveSampleSize(b.Weight)
+
+}
+
+
+// effective number of bounded binaries, given their weights
+func (b *Binary) effectiveNumberOfBounded () float64 {
+
+   if b.totals != nil {
+      return effectiveSize(b.totals.weightBounded, b.totals.weightBounded2)
+   }
+
+   return EffectiveSampleSize(b.WeightBounded)
+
+}
+
+
+// fraction of kicks that leave the binary bounded, taking into account the weight of each kick
+func (b *Binary) BoundedFraction () float64 {
+
+   total, _, bounded, _ := b.weightSums()
+   if total == 0 {
+      return 0
+   }
+
+   return bounded / total
+
+}
+
+
+// standard error of the fraction of kicks that leave the binary bounded. With weighted kicks,
+// it is the linearized error of the ratio of the sums of weights
+func (b *Binary) BoundedFractionError () float64 {
+
+   total, q, _, qBounded := b.weightSums()
+   if total == 0 {
+      return 0
    }
 
+   f := b.BoundedFraction()
+
+   return math.Sqrt(math.Pow(1.0-f,2.0) * qBounded + math.Pow(f,2.0) * (q - qBounded)) / total
+
 }
 
 
 // divide orbital parameter in a grid
-func (b *Binary) GridOfOrbits () {
+func (b *Binary) GridOfOrbits () error {
 
    if b.LogLevel != "none" {
-      msg := "calculating grid of orbits for: " + strconv.Itoa(len(b.IndexBounded)) + " cases"
+      msg := "calculating grid of orbits for: " + strconv.Itoa(b.numberOfBounded()) + " cases"
       io.LogInfo("ORBITS - orbits.go - GridOfOrbits", msg)
    }
 
-   // temporary arrays, stat.Quantile needs sorted arrays
-   x := make([]float64, len(b.IndexBounded))
-   y := make([]float64, len(b.IndexBounded))
-   for k, _ := range b.IndexBounded {
-      x[k] = b.PeriodBounded[k]
-      y[k] = b.EccentricityBounded[k]
+   if b.numberOfBounded() == 0 {
+      return &GridError{Message: "no bounded binaries to make a grid of orbits"}
    }
-   sort.Float64s(x)
-   sort.Float64s(y)
 
-   // find quantiles according to limits given
-   pMin := stat.Quantile(b.PQuantileMin, 1, x, nil)
-   pMax := stat.Quantile(b.PQuantileMax, 1, x, nil)
-   eMin := stat.Quantile(b.EQuantileMin, 1, y, nil)
-   eMax := stat.Quantile(b.EQuantileMax, 1, y, nil)
+   // grid of previous calls is replaced
+   b.resetGrid()
+
+   // options are checked before making the grid
+   err := b.checkGridOptions()
+   if err != nil {
+      return err
+   }
 
+   // borders in grid
+   pBorders, eBorders, err := b.gridBorders()
+   if err != nil {
+      return err
+   }
+   b.printGridLimits(pBorders, eBorders)
+
+   var inGrid float64
+   if b.GridMethod == "adaptive" {
+      // cells are split from the box set by the borders until they hold a target mass
+      b.PeriodBordersGrid = []float64{pBorders[0], pBorders[len(pBorders)-1]}
+      b.EccentricityBordersGrid = []float64{eBorders[0], eBorders[len(eBorders)-1]}
+      inGrid, err = b.adaptiveGridOfOrbits(pBorders[0], pBorders[len(pBorders)-1], eBorders[0], eBorders[len(eBorders)-1])
+      if err != nil {
+         return err
+      }
+   } else {
+      b.PeriodBordersGrid = pBorders
+      b.EccentricityBordersGrid = eBorders
+      inGrid, err = b.regularGridOfOrbits(pBorders, eBorders)
+      if err != nil {
+         return err
+      }
+   }
+
+   b.finishGrid(inGrid)
+
+   return nil
+
+}
+
+
+// check the options of the grid of orbits
+func (b *Binary) checkGridOptions () error {
+
+   switch b.GridMethod {
+   case "", "histogram", "kde", "adaptive":
+   default:
+      return &ConfigError{Option: "grid_method", Message: "unknown value \"" + b.GridMethod + "\", options are: histogram, kde, adaptive"}
+   }
+   if b.GridNormalization != "bounded" && b.GridNormalization != "kick" {
+      return &ConfigError{Option: "grid_normalization", Message: "unknown value \"" + b.GridNormalization + "\", options are: bounded, kick"}
+   }
+   if b.GridIntervalMethod != "multinomial" && b.GridIntervalMethod != "bootstrap" {
+      return &ConfigError{Option: "grid_interval_method", Message: "unknown value \"" + b.GridIntervalMethod + "\", options are: multinomial, bootstrap"}
+   }
+   scales := []struct {
+      option string
+      scale string
+   }{
+      {"period_scale", b.PeriodScale},
+      {"eccentricity_scale", b.EccentricityScale},
+   }
+   for _, a := range scales {
+      if a.scale != "log" && a.scale != "linear" {
+         return &ConfigError{Option: a.option, Message: "unknown value \"" + a.scale + "\", options are: linear, log"}
+      }
+   }
+
+   // resampling a density estimate for every cell is too expensive, bootstrap is only used for
+   // histograms
+   if b.GridMethod == "kde" && b.GridIntervalMethod == "bootstrap" {
+      return &ConfigError{Option: "grid_interval_method", Message: "bootstrap intervals are not available with `grid_method: kde`, use multinomial"}
+   }
+
+   return nil
+
+}
+
+
+// limits of the grid to terminal
+func (b *Binary) printGridLimits (pBorders []float64, eBorders []float64) {
 
    if b.LogLevel != "none" {
       fmt.Println("\nGrid of orbits")
-      fmt.Printf("period quantiles: %.2E, %.2E\n", pMin/24.0/3600.0, pMax/24.0/3600.0)
-      fmt.Printf("eccentricity quantiles: %.2f, %.2f\n", eMin, eMax)
+      fmt.Printf("period limits: %.2E, %.2E\n", units.Time(pBorders[0]).In(units.Day), units.Time(pBorders[len(pBorders)-1]).In(units.Day))
+      fmt.Printf("eccentricity limits: %.2f, %.2f\n", eBorders[0], eBorders[len(eBorders)-1])
    }
 
-   // borders in grid
-   pBorders := LogSpace(math.Log10(pMin), math.Log10(pMax), b.PNum, 10.0)
-   eBorders := LinSpace(eMin, eMax, b.ENum)
+}
 
-   // make grid using borders
-   pGrid := make([]float64, b.PNum-1)
-   for k := 1; k < len(pBorders); k++ {
-      pGrid[k-1] = math.Sqrt(pBorders[k-1] * pBorders[k])
+
+// probability left out of the grid, normalization of the grid and its output to terminal, once
+// cells are set. inGrid is the probability of all cells, including those below the minimum
+func (b *Binary) finishGrid (inGrid float64) {
+
+   // probability that is not part of the grid, either outside of its borders or in cells below
+   // the minimum probability
+   b.ProbabilityOutsideGrid = math.Max(0, 1.0 - inGrid)
+   b.ProbabilityBelowThreshold = math.Max(0, inGrid - floats.Sum(b.ProbabilityGrid))
+
+   // probabilities are per bounded binary, unless they should be per kick
+   if b.GridNormalization == "kick" {
+      f := b.BoundedFraction()
+      floats.Scale(f, b.ProbabilityGrid)
+      floats.Scale(f, b.ProbabilityLowerGrid)
+      floats.Scale(f, b.ProbabilityUpperGrid)
+      for i, _ := range b.ProbabilityMatrix {
+         floats.Scale(f, b.ProbabilityMatrix[i])
+         floats.Scale(f, b.ProbabilityLowerMatrix[i])
+         floats.Scale(f, b.ProbabilityUpperMatrix[i])
+      }
+      b.ProbabilityOutsideGrid *= f
+      b.ProbabilityBelowThreshold *= f
    }
 
-   eGrid := make([]float64, b.ENum-1)
-   for k := 1; k < len(eBorders); k++ {
-      eGrid[k-1] = 0.5 * (eBorders[k-1] + eBorders[k])
+   if b.LogLevel != "none" {
+      fmt.Printf("probabilities per: %s\n", b.GridNormalization)
+      fmt.Printf("probability outside grid: %.4f\n", b.ProbabilityOutsideGrid)
+      fmt.Printf("probability below minimum: %.4f\n", b.ProbabilityBelowThreshold)
+      if b.TerminalPlots {
+         b.printGridHeatmap()
+      }
    }
 
-   // compute 2D-grid of probabilities
+   // output grid above probability minimum
+   if b.LogLevel != "none" {
+      fmt.Println("\nGrid of orbits above minimum probability")
+      fmt.Println("  id      period   separation   eccentricity")
+      last_index := 0
+      for k, _ := range b.PeriodGrid {
+         last_index = k
+      }
+      digits := CountDigits(last_index)
+      for k, _ := range b.PeriodGrid {
+         fmt.Printf("  %0*d    %.2E     %.2E       %.2E\n", digits, k, units.Time(b.PeriodGrid[k]).In(units.Day), units.Length(b.SeparationGrid[k]).In(units.SolarRadius), b.EccentricityGrid[k])
+      }
+      fmt.Printf("\n")
+   }
+
+}
+
+
+// rectangular grid with cells above a minimum probability. It returns the probability of all
+// cells, including those below the minimum
+func (b *Binary) regularGridOfOrbits (pBorders []float64, eBorders []float64) (float64, error) {
+
+   // compute 2D-grid of probabilities, either counting binaries in each cell or integrating a
+   // smooth density estimate over it
+   var probabilities [][]float64
+   if b.GridMethod == "kde" {
+      k, err := b.OrbitsKDE()
+      if err != nil {
+         return 0, err
+      }
+      probabilities = k.Grid(pBorders, eBorders)
+   } else {
+      probabilities, _ = b.histogramOfOrbits(pBorders, eBorders)
+   }
+
+   return b.gridFromProbabilities(pBorders, eBorders, probabilities), nil
+
+}
+
+
+// rectangular grid with cells above a minimum probability, given the probability of every cell
+// (rows are eccentricities and columns periods). It returns the probability of all cells
+func (b *Binary) gridFromProbabilities (pBorders []float64, eBorders []float64, probabilities [][]float64) float64 {
+
+   pGrid, eGrid := b.cellCentres(pBorders, eBorders)
    nRows := len(eGrid)
    nCols := len(pGrid)
-   probabilities := make([][]float64, nRows)
+   intervals := b.gridIntervals(pBorders, eBorders, probabilities)
+
+   // keep every cell, needed to rebuild the whole grid (e.g. for heatmaps)
+   b.ProbabilityMatrix = probabilities
+   b.ProbabilityLowerMatrix = make([][]float64, nRows)
+   b.ProbabilityUpperMatrix = make([][]float64, nRows)
    for i := 0; i < nRows; i++ {
-      probabilities[i] = make([]float64, nCols)
+      b.ProbabilityLowerMatrix[i] = make([]float64, nCols)
+      b.ProbabilityUpperMatrix[i] = make([]float64, nCols)
       for j := 0; j < nCols; j++ {
-      probabilities[i][j] = 0.0
+         b.ProbabilityLowerMatrix[i][j] = intervals[i][j][0]
+         b.ProbabilityUpperMatrix[i][j] = intervals[i][j][1]
       }
    }
 
-   // loop over each binary bounded after kick
-   if b.LogLevel == "debug" {
-      io.LogInfo("ORBITS - orbits.go - GridOfOrbits", "start loop over random binaries")
-   }
-   for k := 0; k < len(b.IndexBounded); k++ {
-      // temporary vars
-      p := b.PeriodBounded[k]
-      e := b.EccentricityBounded[k]
-      for i := 0; i < nRows; i++ {
-         if e >= eBorders[i] && e < eBorders[i+1] {
-            for j:= 0; j < nCols; j++ {
-               if p >= pBorders[j] && p < pBorders[j+1] {
-                  probabilities[i][j] += 1 / float64(len(b.IndexBounded))
-                  if b.LogLevel == "debug" {
-                     fmt.Printf("lower < period < upper: %.2e, %.2e, %.2e\n", pBorders[j]/24.0/3600.0, p/24.0/3600.0, pBorders[j+1]/24.0/3600.0)
-                     fmt.Printf("lower < eccentricity < upper: %.2e, %.2e, %.2e\n\n", eBorders[i], e, eBorders[i+1])
-                  }
-               }
-            }
-         }
-      }
-   }
    // some more output for debugging mode
    if b.LogLevel == "debug" {
       for i := 0; i < nRows; i++ {
@@ -311,24 +766,164 @@ func (b *Binary) GridOfOrbits () {
          if probabilities[i][j] > b.MinProb {
             b.PeriodGrid = append(b.PeriodGrid, pGrid[j])
             b.EccentricityGrid = append(b.EccentricityGrid, eGrid[i])
-            b.SeparationGrid = append(b.SeparationGrid, PtoA(pGrid[j], b.M1, b.M2))
+            b.SeparationGrid = append(b.SeparationGrid, float64(PtoA(units.Time(pGrid[j]), b.massCO(), b.mass2())))
+            b.PeriodLowerGrid = append(b.PeriodLowerGrid, pBorders[j])
+            b.PeriodUpperGrid = append(b.PeriodUpperGrid, pBorders[j+1])
+            b.EccentricityLowerGrid = append(b.EccentricityLowerGrid, eBorders[i])
+            b.EccentricityUpperGrid = append(b.EccentricityUpperGrid, eBorders[i+1])
             b.ProbabilityGrid = append(b.ProbabilityGrid, probabilities[i][j])
+            b.ProbabilityLowerGrid = append(b.ProbabilityLowerGrid, intervals[i][j][0])
+            b.ProbabilityUpperGrid = append(b.ProbabilityUpperGrid, intervals[i][j][1])
          }
       }
    }
-   // output grid above probability minimum
-   if b.LogLevel != "none" {
-      fmt.Println("\nGrid of orbits above minimum probability")
-      fmt.Println("  id      period   separation   eccentricity")
-      last_index := 0
-      for k, _ := range b.PeriodGrid {
-         last_index = k
+
+   total := 0.0
+   for i := 0; i < nRows; i++ {
+      total += floats.Sum(probabilities[i])
+   }
+
+   return total
+
+}
+
+
+// centres of the cells of a grid, that follow the scale of each axis
+func (b *Binary) cellCentres (pBorders []float64, eBorders []float64) ([]float64, []float64) {
+
+   pGrid := make([]float64, len(pBorders)-1)
+   for k := 1; k < len(pBorders); k++ {
+      pGrid[k-1] = 0.5 * (pBorders[k-1] + pBorders[k])
+      if b.PeriodScale == "log" {
+         pGrid[k-1] = math.Sqrt(pBorders[k-1] * pBorders[k])
       }
-      digits := CountDigits(last_index)
-      for k, _ := range b.PeriodGrid {
-         fmt.Printf("  %0*d    %.2E     %.2E       %.2E\n", digits, k, b.PeriodGrid[k]/24.0/3600.0, b.SeparationGrid[k] / Rsun, b.EccentricityGrid[k])
+   }
+
+   eGrid := make([]float64, len(eBorders)-1)
+   for k := 1; k < len(eBorders); k++ {
+      eGrid[k-1] = 0.5 * (eBorders[k-1] + eBorders[k])
+      if b.EccentricityScale == "log" {
+         eGrid[k-1] = math.Sqrt(eBorders[k-1] * eBorders[k])
+      }
+   }
+
+   return pGrid, eGrid
+
+}
+
+
+// borders of the grid in period & eccentricity. For each axis, explicit edges take precedence,
+// then explicit limits (if max > min) and, otherwise, the quantiles of the bounded binaries (of
+// their sketches in streaming runs)
+func (b *Binary) gridBorders () ([]float64, []float64, error) {
+
+   var pQuantile, eQuantile func (q float64) float64
+   if b.totals != nil {
+      pQuantile = b.totals.period.Quantile
+      eQuantile = b.totals.eccentricity.Quantile
+   } else {
+      // temporary arrays, quantiles need sorted arrays (together with their weights)
+      x, xWeight := SortWithWeights(b.PeriodBounded, b.WeightBounded)
+      y, yWeight := SortWithWeights(b.EccentricityBounded, b.WeightBounded)
+      pQuantile = func (q float64) float64 { return WeightedQuantile(q, x, xWeight) }
+      eQuantile = func (q float64) float64 { return WeightedQuantile(q, y, yWeight) }
+   }
+
+   // explicit borders in period are given in days
+   day := float64(units.Day)
+   pEdges := make([]float64, len(b.PeriodEdges))
+   floats.ScaleTo(pEdges, day, b.PeriodEdges)
+   pBorders, err := axisBorders("period", pEdges, b.PeriodMin * day, b.PeriodMax * day, b.PeriodScale, b.PNum, b.PQuantileMin, b.PQuantileMax, pQuantile)
+   if err != nil {
+      return nil, nil, err
+   }
+   eBorders, err := axisBorders("eccentricity", b.EccentricityEdges, b.EccentricityMin, b.EccentricityMax, b.EccentricityScale, b.ENum, b.EQuantileMin, b.EQuantileMax, eQuantile)
+   if err != nil {
+      return nil, nil, err
+   }
+
+   return pBorders, eBorders, nil
+
+}
+
+
+// whether the borders of both axes of the grid are set by the config, without quantiles
+func (b *Binary) fixedGridBorders () bool {
+
+   return (len(b.PeriodEdges) > 0 || b.PeriodMax > b.PeriodMin) && (len(b.EccentricityEdges) > 0 || b.EccentricityMax > b.EccentricityMin)
+
+}
+
+
+// borders of one axis of the grid: edges if given (and increasing), or nBorders borders between
+// min & max (if max > min) or between the quantiles qMin & qMax of the values
+func axisBorders (name string, edges []float64, min float64, max float64, scale string, nBorders int, qMin float64, qMax float64, quantile func (q float64) float64) ([]float64, error) {
+
+   if len(edges) > 0 {
+      if len(edges) >= 2 && sort.Float64sAreSorted(edges) && edges[0] < edges[len(edges)-1] {
+         return append([]float64{}, edges...), nil
+      }
+      return nil, &GridError{Message: name + " edges must be at least two increasing values"}
+   }
+   if nBorders < 2 {
+      return nil, &GridError{Message: "number of " + name + " borders must be at least 2"}
+   }
+
+   if max <= min {
+      // find quantiles according to limits given
+      min = quantile(qMin)
+      max = quantile(qMax)
+   }
+
+   if scale == "log" {
+      if min <= 0 {
+         return nil, &GridError{Message: "log scale needs positive " + name + " limits"}
+      }
+      return LogSpace(math.Log10(min), math.Log10(max), nBorders, 10.0)
+   }
+
+   return LinSpace(min, max, nBorders)
+
+}
+
+
+// weighted histogram of bounded binaries in a grid of period & eccentricity. Rows are
+// eccentricities and columns periods. It returns the probability of each cell and the sum of
+// squared normalized weights in it, needed for standard errors
+func (b *Binary) histogramOfOrbits (pBorders []float64, eBorders []float64) ([][]float64, [][]float64) {
+
+   nRows := len(eBorders) - 1
+   nCols := len(pBorders) - 1
+   probabilities := make([][]float64, nRows)
+   squares := make([][]float64, nRows)
+   for i := 0; i < nRows; i++ {
+      probabilities[i] = make([]float64, nCols)
+      squares[i] = make([]float64, nCols)
+   }
+
+   // loop over each binary bounded after kick
+   if b.LogLevel == "debug" {
+      io.LogInfo("ORBITS - orbits.go - GridOfOrbits", "start loop over random binaries")
+   }
+   totalWeight := floats.Sum(b.WeightBounded)
+   for k := 0; k < len(b.IndexBounded); k++ {
+      // temporary vars
+      p := b.PeriodBounded[k]
+      e := b.EccentricityBounded[k]
+      w := b.WeightBounded[k] / totalWeight
+      i := cellIndex(e, eBorders)
+      j := cellIndex(p, pBorders)
+      if i < 0 || j < 0 {
+         continue
+      }
+      probabilities[i][j] += w
+      squares[i][j] += w * w
+      if b.LogLevel == "debug" {
+         fmt.Printf("lower < period < upper: %.2e, %.2e, %.2e\n", units.Time(pBorders[j]).In(units.Day), units.Time(p).In(units.Day), units.Time(pBorders[j+1]).In(units.Day))
+         fmt.Printf("lower < eccentricity < upper: %.2e, %.2e, %.2e\n\n", eBorders[i], e, eBorders[i+1])
       }
-      fmt.Printf("\n")
    }
 
+   return probabilities, squares
+
 }
@@ -0,0 +1,67 @@
+package orbits
+
+import (
+   "testing"
+)
+
+
+// a log scale with limits that are not positive is an error, instead of cells with NaN centres
+func TestAxisBordersLogScale (t *testing.T) {
+
+   quantile := func (q float64) float64 { return q }
+
+   _, err := axisBorders("eccentricity", nil, 0, 1, "log", 10, 0, 1, quantile)
+   if _, ok := err.(*GridError); !ok {
+      t.Errorf("log scale from 0: got error %v, want a GridError", err)
+   }
+   _, err = axisBorders("eccentricity", nil, 0, 0, "log", 10, 0, 1, quantile)
+   if _, ok := err.(*GridError); !ok {
+      t.Errorf("log scale from quantile 0: got error %v, want a GridError", err)
+   }
+
+   borders, err := axisBorders("period", nil, 1, 100, "log", 3, 0, 1, quantile)
+   if err != nil {
+      t.Fatal(err)
+   }
+   if len(borders) != 3 || relativeDifference(borders[1], 10) > 1e-12 {
+      t.Errorf("log scale between 1 & 100: got %v, want [1 10 100]", borders)
+   }
+
+}
+
+
+// bootstrap intervals of kde grids are a configuration error, not a fall back to multinomial
+func TestCheckGridOptionsBootstrapKDE (t *testing.T) {
+
+   cfg := testConfig(1000)
+   cfg.GridMethod, cfg.GridIntervalMethod = "kde", "bootstrap"
+   b := NewBinary(cfg)
+
+   err := b.checkGridOptions()
+   if e, ok := err.(*ConfigError); !ok || e.Option != "grid_interval_method" {
+      t.Errorf("bootstrap with kde: got error %v, want a ConfigError on grid_interval_method", err)
+   }
+
+}
+
+
+// scales of the grid other than linear & log are configuration errors
+func TestCheckGridOptionsScales (t *testing.T) {
+
+   cfg := testConfig(1000)
+   cfg.PeriodScale = "logarithmic"
+   b := NewBinary(cfg)
+   err := b.checkGridOptions()
+   if e, ok := err.(*ConfigError); !ok || e.Option != "period_scale" {
+      t.Errorf("period_scale logarithmic: got error %v, want a ConfigError on period_scale", err)
+   }
+
+   cfg = testConfig(1000)
+   cfg.EccentricityScale = "lin"
+   b = NewBinary(cfg)
+   err = b.checkGridOptions()
+   if e, ok := err.(*ConfigError); !ok || e.Option != "eccentricity_scale" {
+      t.Errorf("eccentricity_scale lin: got error %v, want a ConfigError on eccentricity_scale", err)
+   }
+
+}
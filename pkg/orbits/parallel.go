@@ -0,0 +1,87 @@
+package orbits
+
+import (
+   "runtime"
+   "sync"
+)
+
+
+// number of kicks of each chunk processed by a worker. Chunks are set by the index of their
+// kicks only, so that results do not depend on the number of workers
+const kickChunk = 1 << 14
+
+
+// number of goroutines that draw kicks & solve orbits: Workers or, if not set, GOMAXPROCS. Debug
+// output is printed kick by kick, so it is made by a single worker
+func (b *Binary) workers () int {
+
+   if b.LogLevel == "debug" {
+      return 1
+   }
+   if b.Workers > 0 {
+      return b.Workers
+   }
+
+   return runtime.GOMAXPROCS(0)
+
+}
+
+
+// number of chunks of n kicks
+func numberOfChunks (n int) int {
+
+   if n <= 0 {
+      return 0
+   }
+
+   return (n + kickChunk - 1) / kickChunk
+
+}
+
+
+// seed of the random stream of the chunk whose first kick has index first. Seeds are mixed
+// (SplitMix64) from the seed of the config, so that streams of neighbouring chunks are independent
+func chunkSeed (seed uint64, first int) uint64 {
+
+   z := seed + uint64(first + 1) * 0x9e3779b97f4a7c15
+   z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+   z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+
+   return z ^ (z >> 31)
+
+}
+
+
+// call f, from up to workers goroutines, for every chunk c of kicks with index between lo
+// (included) and hi (excluded), that split kicks between first & last
+func forEachChunk (first int, last int, workers int, f func (c int, lo int, hi int)) {
+
+   nChunks := numberOfChunks(last - first)
+   if workers > nChunks {
+      workers = nChunks
+   }
+
+   chunks := make(chan int)
+   var wg sync.WaitGroup
+   for k := 0; k < workers; k++ {
+      wg.Add(1)
+      go func () {
+         defer wg.Done()
+         for c := range chunks {
+            lo := first + c * kickChunk
+            hi := lo + kickChunk
+            if hi > last {
+               hi = last
+            }
+            f(c, lo, hi)
+         }
+      }()
+   }
+
+   for c := 0; c < nChunks; c++ {
+      chunks <- c
+   }
+   close(chunks)
+   wg.Wait()
+
+}
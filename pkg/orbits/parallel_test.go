@@ -0,0 +1,121 @@
+package orbits
+
+import (
+   "context"
+   "reflect"
+   "runtime"
+   "strconv"
+   "testing"
+)
+
+
+// options of a binary that loses part of its bounded binaries, with n kicks and neither terminal
+// output nor files
+func testConfig (n int) Config {
+
+   cfg := DefaultConfig()
+   cfg.M1, cfg.M2, cfg.MCO = 8.35, 32.6, 1.66
+   cfg.Separation = 73.6
+   cfg.KickStrengthDistribution, cfg.KickDirection = "Maxwell", "Uniform"
+   cfg.SigmaStrength = 265.0
+   cfg.MinPhi, cfg.MaxPhi = 0.0, 2.0
+   cfg.Sampling = "random"
+   cfg.Seed = 1000
+   cfg.NumberOfCases = n
+   cfg.LogLevel = "none"
+   cfg.PQuantileMin, cfg.PQuantileMax = 0.05, 0.95
+   cfg.EQuantileMin, cfg.EQuantileMax = 0.0, 1.0
+   cfg.PNum, cfg.ENum = 25, 10
+   cfg.MinProb = 0.01
+   cfg.GridMethod = "histogram"
+
+   return cfg
+
+}
+
+
+// results must not depend on the number of workers, with more kicks than a chunk
+func TestRunWorkers (t *testing.T) {
+
+   cfg := testConfig(3 * kickChunk + 123)
+   cfg.Workers = 1
+   serial, err := Run(context.Background(), cfg)
+   if err != nil {
+      t.Fatal(err)
+   }
+   cfg.Workers = 7
+   parallel, err := Run(context.Background(), cfg)
+   if err != nil {
+      t.Fatal(err)
+   }
+
+   if len(serial.Orbits) == 0 {
+      t.Fatal("no bounded binaries to compare")
+   }
+   if !reflect.DeepEqual(serial.Kicks, parallel.Kicks) {
+      t.Error("kicks with 1 and 7 workers are different")
+   }
+   if !reflect.DeepEqual(serial.Orbits, parallel.Orbits) {
+      t.Error("orbits with 1 and 7 workers are different")
+   }
+   if !reflect.DeepEqual(serial.Grid, parallel.Grid) {
+      t.Error("grids with 1 and 7 workers are different")
+   }
+
+}
+
+
+// numbers of workers of the benchmarks: one and every processor (at least 4, so that workers
+// are compared even with a single processor)
+func benchmarkWorkers () []int {
+
+   n := runtime.GOMAXPROCS(0)
+   if n < 4 {
+      n = 4
+   }
+
+   return []int{1, n}
+
+}
+
+
+func BenchmarkDrawKicks (b *testing.B) {
+
+   for _, workers := range benchmarkWorkers() {
+      b.Run("workers=" + strconv.Itoa(workers), func (b *testing.B) {
+         cfg := testConfig(16 * kickChunk)
+         cfg.Workers = workers
+         for k := 0; k < b.N; k++ {
+            binary := NewBinary(cfg)
+            err := binary.drawKicks(cfg.NumberOfCases)
+            if err != nil {
+               b.Fatal(err)
+            }
+         }
+      })
+   }
+
+}
+
+
+func BenchmarkSolveOrbits (b *testing.B) {
+
+   for _, workers := range benchmarkWorkers() {
+      b.Run("workers=" + strconv.Itoa(workers), func (b *testing.B) {
+         cfg := testConfig(16 * kickChunk)
+         cfg.Workers = workers
+         binary := NewBinary(cfg)
+         err := binary.drawKicks(cfg.NumberOfCases)
+         if err != nil {
+            b.Fatal(err)
+         }
+         binary.ConvertoCGS()
+         b.ResetTimer()
+         for k := 0; k < b.N; k++ {
+            binary.resetOrbits()
+            binary.solveOrbits(0, cfg.NumberOfCases)
+         }
+      })
+   }
+
+}
@@ -0,0 +1,188 @@
+package orbits
+
+import (
+   "crypto/sha256"
+   "encoding/hex"
+   "encoding/json"
+   "runtime/debug"
+   "strconv"
+   "time"
+)
+
+
+// version of the program, set at build time with
+// -ldflags "-X github.com/asimazbunzel/go-orbits/pkg/orbits.Version=..."
+var Version = ""
+
+
+// version of the program and git revision it was built from (with "-modified" if the tree had
+// changes), as far as they are known
+func buildVersion () (string, string) {
+
+   version := Version
+   revision := "unknown"
+
+   info, ok := debug.ReadBuildInfo()
+   if !ok {
+      if version == "" {
+         version = "unknown"
+      }
+      return version, revision
+   }
+
+   if version == "" {
+      version = info.Main.Version
+   }
+   modified := false
+   for _, s := range info.Settings {
+      switch s.Key {
+      case "vcs.revision":
+         revision = s.Value
+      case "vcs.modified":
+         modified = s.Value == "true"
+      }
+   }
+   if modified {
+      revision += "-modified"
+   }
+
+   return version, revision
+
+}
+
+
+// hash (SHA-256) of the options of the configuration, to tell apart outputs of different configs
+func configHash (keywords []Keyword) string {
+
+   data, _ := json.Marshal(keywords)
+   sum := sha256.Sum256(data)
+
+   return hex.EncodeToString(sum[:])
+
+}
+
+
+// start of the run and hash of the configuration as loaded, before anything is changed by the run
+func (b *Binary) initProvenance () {
+
+   b.Timestamp = time.Now().UTC().Format(time.RFC3339)
+   b.ConfigHash = configHash(b.ConfigKeywords())
+
+}
+
+
+// keywords that record how an output was made: version & revision of the program, time of the
+// run, hash of the configuration and summary statistics of the kicks
+func (b *Binary) ProvenanceKeywords () []Keyword {
+
+   return append(b.runKeywords(), b.summaryKeywords()...)
+
+}
+
+
+// keywords of the program & run, known before any kick is made
+func (b *Binary) runKeywords () []Keyword {
+
+   version, revision := buildVersion()
+
+   return []Keyword{
+      {Name: "program", Value: "go-orbits"},
+      {Name: "version", Value: version},
+      {Name: "revision", Value: revision},
+      {Name: "timestamp", Value: b.Timestamp},
+      {Name: "config_file", Value: b.ConfigFile},
+      {Name: "config_hash", Value: b.ConfigHash},
+   }
+
+}
+
+
+// keywords with summary statistics of the kicks
+func (b *Binary) summaryKeywords () []Keyword {
+
+   formatFloat := func (x float64) string {
+      return strconv.FormatFloat(x, 'g', -1, 64)
+   }
+
+   keywords := []Keyword{
+      {Name: "number_of_kicks", Value: strconv.Itoa(b.numberOfKicks())},
+      {Name: "number_of_bounded", Value: strconv.Itoa(b.numberOfBounded())},
+      {Name: "bounded_fraction", Value: formatFloat(b.BoundedFraction())},
+      {Name: "bounded_fraction_wilson_lower", Value: formatFloat(b.BoundedFractionWilson[0])},
+      {Name: "bounded_fraction_wilson_upper", Value: formatFloat(b.BoundedFractionWilson[1])},
+      {Name: "bounded_fraction_clopper_pearson_lower", Value: formatFloat(b.BoundedFractionClopperPearson[0])},
+      {Name: "bounded_fraction_clopper_pearson_upper", Value: formatFloat(b.BoundedFractionClopperPearson[1])},
+      {Name: "effective_number_of_kicks", Value: formatFloat(b.effectiveNumberOfKicks())},
+   }
+   if b.ConvergenceTarget != "" && b.ConvergenceTarget != "none" {
+      keywords = append(keywords, Keyword{Name: "achieved_error", Value: formatFloat(b.AchievedError)})
+   }
+
+   return keywords
+
+}
+
+
+// provenance followed by every option of the configuration, as stored in outputs
+func (b *Binary) MetadataKeywords () []Keyword {
+
+   return append(b.ProvenanceKeywords(), b.ConfigKeywords()...)
+
+}
+
+
+// metadata of an output, written as JSON next to it (sidecar) when its format cannot hold it
+type Manifest struct {
+   File string `json:"file"`
+   Format string `json:"format"`
+   Metadata map[string]string `json:"metadata"`
+   Units map[string]string `json:"units"`
+   Columns []string `json:"columns"`
+   Comments []string `json:"comments,omitempty"`
+}
+
+
+// manifest of a table written to filename in format
+func newManifest (filename string, format string, t Table) Manifest {
+
+   m := Manifest{File: filename, Format: format, Metadata: make(map[string]string), Units: make(map[string]string), Comments: t.Comments}
+   for _, kw := range t.Keywords {
+      m.Metadata[kw.Name] = kw.Value
+   }
+   for _, c := range t.Columns {
+      m.Columns = append(m.Columns, c.Name)
+      if c.Unit != "" {
+         m.Units[c.Name] = c.Unit
+      }
+   }
+
+   return m
+
+}
+
+
+// keywords & units of a table as lines of text (e.g. for comments of text files)
+func metadataLines (t Table) []string {
+
+   var lines []string
+   for _, kw := range t.Keywords {
+      lines = append(lines, kw.Name + ": " + kw.Value)
+   }
+
+   var units []string
+   for _, c := range t.Columns {
+      if c.Unit != "" {
+         units = append(units, c.Name + "=" + c.Unit)
+      }
+   }
+   if len(units) > 0 {
+      line := "units:"
+      for _, u := range units {
+         line += " " + u
+      }
+      lines = append(lines, line)
+   }
+
+   return lines
+
+}
@@ -0,0 +1,72 @@
+package orbits
+
+import (
+   "math"
+
+   "gonum.org/v1/gonum/integrate/quad"
+   "gonum.org/v1/gonum/stat/distuv"
+)
+
+
+// append kicks, in km/s, placed on a tensor-product grid in (w, cos(theta), phi) instead of
+// drawing them at random. Each kick gets the weight of its node in the quadrature of the kick
+// distribution, normalized so that weights have a mean of unity:
+//   - w: Gauss-Legendre nodes in the cumulative distribution of the strength of the kick, so
+//     that the weights of the nodes already include its probability density
+//   - cos(theta): Gauss-Legendre nodes between -1 and 1 (isotropic kicks)
+//   - phi: midpoints of QuadraturePhiPoints equal intervals between MinPhi & MaxPhi
+func (b *Binary) quadratureKicks () error {
+
+   if b.QuadratureKickPoints <= 0 || b.QuadratureThetaPoints <= 0 || b.QuadraturePhiPoints <= 0 {
+      return &ConfigError{Option: "quadrature_kick_points", Message: "number of quadrature points (of kicks, theta & phi) must be greater than 0"}
+   }
+
+   if b.KickDirection != "Uniform" {
+      return &DistributionError{Option: "kick_direction", Value: b.KickDirection, Options: []string{"Uniform"}}
+   }
+
+   if b.KickStrengthDistribution != "Maxwell" && b.KickStrengthDistribution != "Uniform" {
+      return &DistributionError{Option: "kick_distribution", Value: b.KickStrengthDistribution, Options: []string{"Maxwell", "Uniform"}}
+   }
+
+   // nodes & weights in the cumulative distribution of the kick strength
+   u := make([]float64, b.QuadratureKickPoints)
+   uWeight := make([]float64, b.QuadratureKickPoints)
+   quad.Legendre{}.FixedLocations(u, uWeight, 0, 1)
+
+   wNodes := make([]float64, b.QuadratureKickPoints)
+   for k, uk := range u {
+      if b.KickStrengthDistribution == "Maxwell" {
+         wNodes[k] = b.SigmaStrength * math.Sqrt(distuv.ChiSquared{K: 3}.Quantile(uk))
+      } else {
+         wNodes[k] = b.MinKickStrength + uk * (b.MaxKickStrength - b.MinKickStrength)
+      }
+      if b.ReduceByFallback { wNodes[k] *= (1.0 - b.FallbackFraction) }
+   }
+
+   // nodes & weights of cos(theta), with its probability density of 1/2
+   mu := make([]float64, b.QuadratureThetaPoints)
+   muWeight := make([]float64, b.QuadratureThetaPoints)
+   quad.Legendre{}.FixedLocations(mu, muWeight, -1, 1)
+
+   // nodes of phi, all with the same weight
+   phiStep := (b.MaxPhi - b.MinPhi) * math.Pi / float64(b.QuadraturePhiPoints)
+
+   nCases := b.QuadratureKickPoints * b.QuadratureThetaPoints * b.QuadraturePhiPoints
+   for i, w := range wNodes {
+      for j, m := range mu {
+         for k := 0; k < b.QuadraturePhiPoints; k++ {
+            b.W = append(b.W, w)
+            b.Theta = append(b.Theta, math.Acos(m))
+            b.Phi = append(b.Phi, b.MinPhi * math.Pi + (float64(k) + 0.5) * phiStep)
+            weight := uWeight[i] * 0.5 * muWeight[j] / float64(b.QuadraturePhiPoints)
+            b.Weight = append(b.Weight, weight * float64(nCases))
+         }
+      }
+   }
+
+   b.NumberOfCases = len(b.W)
+
+   return nil
+
+}
@@ -0,0 +1,72 @@
+package orbits
+
+import (
+   "context"
+   "math"
+   "testing"
+
+   "gonum.org/v1/gonum/floats"
+)
+
+
+// weights of the quadrature have a mean of unity (they sum to 1 once normalized) and give the
+// moments of the distribution of kicks: <w^2> = 3 sigma^2 for a Maxwellian, <cos(theta)> = 0
+func TestQuadratureWeights (t *testing.T) {
+
+   cfg := testConfig(0)
+   cfg.Sampling = "quadrature"
+   cfg.QuadratureKickPoints, cfg.QuadratureThetaPoints, cfg.QuadraturePhiPoints = 40, 20, 8
+   b := NewBinary(cfg)
+   err := b.quadratureKicks()
+   if err != nil {
+      t.Fatal(err)
+   }
+
+   n := float64(len(b.W))
+   if n != 40 * 20 * 8 {
+      t.Fatalf("got %d kicks, want %d", len(b.W), 40 * 20 * 8)
+   }
+   if total := floats.Sum(b.Weight) / n; math.Abs(total - 1) > 1e-12 {
+      t.Errorf("normalized weights sum to %f, want 1", total)
+   }
+
+   w2, mu := 0.0, 0.0
+   for k, w := range b.W {
+      w2 += b.Weight[k] * w * w / n
+      mu += b.Weight[k] * math.Cos(b.Theta[k]) / n
+   }
+   if d := relativeDifference(w2, 3 * cfg.SigmaStrength * cfg.SigmaStrength); d > 1e-3 {
+      t.Errorf("<w^2>: got %f, want 3 sigma^2 = %f", w2, 3 * cfg.SigmaStrength * cfg.SigmaStrength)
+   }
+   if math.Abs(mu) > 1e-12 {
+      t.Errorf("<cos(theta)>: got %f, want 0", mu)
+   }
+
+}
+
+
+// the fraction of bounded binaries of the quadrature matches that of random kicks, within the
+// error of the latter
+func TestQuadratureBoundedFraction (t *testing.T) {
+
+   cfg := testConfig(100000)
+   random := NewBinary(cfg)
+   err := random.Run(context.Background())
+   if err != nil {
+      t.Fatal(err)
+   }
+
+   cfg.Sampling = "quadrature"
+   cfg.QuadratureKickPoints, cfg.QuadratureThetaPoints, cfg.QuadraturePhiPoints = 64, 32, 16
+   quadrature := NewBinary(cfg)
+   err = quadrature.Run(context.Background())
+   if err != nil {
+      t.Fatal(err)
+   }
+
+   f, fQuadrature := random.BoundedFraction(), quadrature.BoundedFraction()
+   if math.Abs(f - fQuadrature) > 4 * random.BoundedFractionError() {
+      t.Errorf("bounded fraction: got %f with quadrature and %f +/- %f with random kicks", fQuadrature, f, random.BoundedFractionError())
+   }
+
+}
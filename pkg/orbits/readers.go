@@ -0,0 +1,926 @@
+package orbits
+
+import (
+   "archive/zip"
+   "bufio"
+   "bytes"
+   "encoding/binary"
+   "encoding/csv"
+   "encoding/json"
+   "errors"
+   "fmt"
+   "io/ioutil"
+   "math"
+   "path/filepath"
+   "regexp"
+   "sort"
+   "strconv"
+   "strings"
+
+   "github.com/xitongsys/parquet-go-source/buffer"
+   "github.com/xitongsys/parquet-go/reader"
+)
+
+
+// reader of tables from the contents of a file of a given format
+type TableReader interface {
+   Read (data []byte) (Table, error)
+}
+
+
+// reader for a format, the same as for NewTableWriter. Files need not be written by this code:
+// text & csv may have any columns, npy any one-dimensional array of numbers (structured or not),
+// npz any archive of such arrays and fits any binary table with scalar columns
+func NewTableReader (format string) (TableReader, error) {
+
+   switch format {
+   case "", "text":
+      return textReader{}, nil
+   case "csv":
+      return csvReader{}, nil
+   case "jsonl":
+      return jsonlReader{}, nil
+   case "parquet":
+      return parquetReader{}, nil
+   case "npy":
+      return npyReader{}, nil
+   case "npz":
+      return npzReader{}, nil
+   case "fits":
+      return fitsReader{}, nil
+   }
+
+   return nil, errors.New("unknown format: " + format + ", options are: " + strings.Join(outputFormats, ", "))
+
+}
+
+
+// format of a file from its extension, text if it is not one of the other formats
+func formatFromExtension (filename string) string {
+
+   switch strings.ToLower(filepath.Ext(filename)) {
+   case ".csv":
+      return "csv"
+   case ".jsonl", ".ndjson":
+      return "jsonl"
+   case ".parquet", ".pq":
+      return "parquet"
+   case ".npy":
+      return "npy"
+   case ".npz":
+      return "npz"
+   case ".fits", ".fit", ".fts":
+      return "fits"
+   }
+
+   return "text"
+
+}
+
+
+// read a table from filename in format (guessed from the extension of filename if empty). Keywords
+// & units of formats that cannot hold them are read from the sidecar file, if there is one
+func ReadTable (filename string, format string) (Table, error) {
+
+   if format == "" {
+      format = formatFromExtension(filename)
+   }
+   tr, err := NewTableReader(format)
+   if err != nil {
+      return Table{}, &ConfigError{Message: err.Error()}
+   }
+
+   return readTable(filename, format, tr)
+
+}
+
+
+// read a table from filename in format with tr
+func readTable (filename string, format string, tr TableReader) (Table, error) {
+
+   data, err := ioutil.ReadFile(filename)
+   if err != nil {
+      return Table{}, &IOError{Filename: filename, Op: "read", Err: err}
+   }
+   t, err := tr.Read(data)
+   if err != nil {
+      return t, &IOError{Filename: filename, Op: "parse", Err: err}
+   }
+
+   // a plain array has no name of its own
+   if len(t.Columns) == 1 && t.Columns[0].Name == "" {
+      t.Columns[0].Name = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+   }
+
+   if !embedsMetadata(format) {
+      data, err := ioutil.ReadFile(filename + ".meta.json")
+      if err == nil {
+         var m Manifest
+         if json.Unmarshal(data, &m) == nil {
+            m.apply(&t)
+         }
+      }
+   }
+
+   return t, nil
+
+}
+
+
+// column of a table by its name
+func (t Table) Column (name string) (Column, bool) {
+
+   for _, c := range t.Columns {
+      if c.Name == name {
+         return c, true
+      }
+   }
+
+   return Column{}, false
+
+}
+
+
+// value of a keyword of a table by its name
+func (t Table) Keyword (name string) (string, bool) {
+
+   for _, kw := range t.Keywords {
+      if kw.Name == name {
+         return kw.Value, true
+      }
+   }
+
+   return "", false
+
+}
+
+
+// keywords (sorted by name), units & comments of a sidecar file into a table
+func (m Manifest) apply (t *Table) {
+
+   names := make([]string, 0, len(m.Metadata))
+   for name, _ := range m.Metadata {
+      names = append(names, name)
+   }
+   sort.Strings(names)
+   for _, name := range names {
+      t.Keywords = append(t.Keywords, Keyword{Name: name, Value: m.Metadata[name]})
+   }
+   setUnits(t, m.Units)
+   t.Comments = append(t.Comments, m.Comments...)
+
+}
+
+
+// units of columns by their name
+func setUnits (t *Table, units map[string]string) {
+
+   for j, c := range t.Columns {
+      if unit, ok := units[c.Name]; ok {
+         t.Columns[j].Unit = unit
+      }
+   }
+
+}
+
+
+// line of metadata (without the leading #) as written by metadataLines: "units: name=unit ...",
+// "name: value" for keywords and anything else for comments
+func parseMetadataLine (t *Table, units map[string]string, line string) {
+
+   // keywords with no value have nothing after the colon
+   line += " "
+   k := strings.Index(line, ": ")
+   if k <= 0 || strings.ContainsAny(line[:k], " \t") {
+      t.Comments = append(t.Comments, strings.TrimSpace(line))
+      return
+   }
+
+   name, value := line[:k], strings.TrimSpace(line[k+2:])
+   if name == "units" {
+      for _, field := range strings.Fields(value) {
+         l := strings.Index(field, "=")
+         if l > 0 {
+            units[field[:l]] = field[l+1:]
+         }
+      }
+      return
+   }
+
+   t.Keywords = append(t.Keywords, Keyword{Name: name, Value: value})
+
+}
+
+
+// number in a field of text, NaN if it is not a number. Also tells whether it is an integer
+func parseField (field string) (float64, bool) {
+
+   if n, err := strconv.ParseInt(field, 10, 64); err == nil {
+      return float64(n), true
+   }
+   x, err := strconv.ParseFloat(field, 64)
+   if err != nil {
+      return math.NaN(), false
+   }
+
+   return x, false
+
+}
+
+
+// columns of text with the names of header, marked as integers if every value is
+func textColumns (header []string, rows [][]string) ([]Column, error) {
+
+   columns := make([]Column, len(header))
+   for j, name := range header {
+      columns[j] = Column{Name: name, Values: make([]float64, len(rows)), Integer: len(rows) > 0}
+   }
+   for k, fields := range rows {
+      if len(fields) != len(header) {
+         return nil, fmt.Errorf("expected %d columns, found %d in row %d", len(header), len(fields), k + 1)
+      }
+      for j, field := range fields {
+         x, integer := parseField(strings.TrimSpace(field))
+         columns[j].Values[k] = x
+         columns[j].Integer = columns[j].Integer && integer
+      }
+   }
+
+   return columns, nil
+
+}
+
+
+// fixed-width (or any whitespace-separated) text, with metadata in lines starting with #
+type textReader struct{}
+
+func (textReader) Read (data []byte) (Table, error) {
+
+   var t Table
+   var header []string
+   var rows [][]string
+   units := make(map[string]string)
+
+   scanner := bufio.NewScanner(bytes.NewReader(data))
+   // configuration options can make long lines of metadata
+   scanner.Buffer(make([]byte, 64 * 1024), len(data) + 1)
+   for scanner.Scan() {
+      line := strings.TrimSpace(scanner.Text())
+      if line == "" {
+         continue
+      }
+      if strings.HasPrefix(line, "#") {
+         parseMetadataLine(&t, units, strings.TrimSpace(strings.TrimPrefix(line, "#")))
+         continue
+      }
+      if header == nil {
+         header = strings.Fields(line)
+         continue
+      }
+      rows = append(rows, strings.Fields(line))
+   }
+   if err := scanner.Err(); err != nil {
+      return t, err
+   }
+
+   columns, err := textColumns(header, rows)
+   t.Columns = columns
+   setUnits(&t, units)
+
+   return t, err
+
+}
+
+
+// text or csv with rows of other headers (e.g. data types & units of COMPAS logs) above the row
+// with the names of the columns, which are dropped. Rows are dropped until the one with the
+// column name, up to rows of them, so tables without those headers are read as they are
+type headerRowsReader struct {
+   TableReader
+   rows int
+   name string
+}
+
+func (r headerRowsReader) Read (data []byte) (Table, error) {
+
+   lines := bytes.SplitAfter(data, []byte("\n"))
+   dropped := 0
+   for k, line := range lines {
+      text := strings.TrimSpace(string(line))
+      if text == "" || strings.HasPrefix(text, "#") {
+         continue
+      }
+      if dropped == r.rows || hasField(text, r.name) {
+         break
+      }
+      lines[k] = nil
+      dropped++
+   }
+
+   return r.TableReader.Read(bytes.Join(lines, nil))
+
+}
+
+
+// whether a row of text or csv has a field equal to name
+func hasField (row string, name string) bool {
+
+   fields := strings.FieldsFunc(row, func (r rune) bool {
+      return r == ',' || r == ' ' || r == '\t'
+   })
+   for _, field := range fields {
+      if strings.Trim(field, "\"") == name {
+         return true
+      }
+   }
+
+   return false
+
+}
+
+
+// comma-separated values, with metadata in lines starting with # before the header or after the
+// rows (summary keywords of streaming runs)
+type csvReader struct{}
+
+func (csvReader) Read (data []byte) (Table, error) {
+
+   var t Table
+   units := make(map[string]string)
+   var rows bytes.Buffer
+   for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+      text := strings.TrimSpace(string(line))
+      if strings.HasPrefix(text, "#") {
+         parseMetadataLine(&t, units, strings.TrimSpace(strings.TrimPrefix(text, "#")))
+         continue
+      }
+      rows.Write(line)
+   }
+
+   cr := csv.NewReader(&rows)
+   cr.TrimLeadingSpace = true
+   records, err := cr.ReadAll()
+   if err != nil {
+      return t, err
+   }
+   if len(records) == 0 {
+      return t, nil
+   }
+
+   t.Columns, err = textColumns(records[0], records[1:])
+   setUnits(&t, units)
+
+   return t, err
+
+}
+
+
+// one JSON object per row. Columns are in the order of keys of the first row; null, missing
+// values and anything that is not a number become NaN
+type jsonlReader struct{}
+
+func (jsonlReader) Read (data []byte) (Table, error) {
+
+   var t Table
+   index := make(map[string]int)
+   rows := 0
+
+   scanner := bufio.NewScanner(bytes.NewReader(data))
+   scanner.Buffer(make([]byte, 64 * 1024), len(data) + 1)
+   for scanner.Scan() {
+      line := strings.TrimSpace(scanner.Text())
+      if line == "" {
+         continue
+      }
+
+      keys, err := jsonKeys(line)
+      if err != nil {
+         return t, fmt.Errorf("row %d: %v", rows + 1, err)
+      }
+      var row map[string]interface{}
+      dec := json.NewDecoder(strings.NewReader(line))
+      dec.UseNumber()
+      err = dec.Decode(&row)
+      if err != nil {
+         return t, fmt.Errorf("row %d: %v", rows + 1, err)
+      }
+
+      // keys not seen before are new columns, NaN in previous rows
+      for _, key := range keys {
+         if _, ok := index[key]; !ok {
+            index[key] = len(t.Columns)
+            values := make([]float64, rows)
+            for k, _ := range values {
+               values[k] = math.NaN()
+            }
+            t.Columns = append(t.Columns, Column{Name: key, Values: values, Integer: true})
+         }
+      }
+      for j, c := range t.Columns {
+         x, integer := math.NaN(), false
+         if number, ok := row[c.Name].(json.Number); ok {
+            x, integer = parseField(number.String())
+         }
+         t.Columns[j].Values = append(c.Values, x)
+         t.Columns[j].Integer = c.Integer && integer
+      }
+      rows++
+   }
+
+   return t, scanner.Err()
+
+}
+
+
+// keys of a JSON object, in order
+func jsonKeys (line string) ([]string, error) {
+
+   dec := json.NewDecoder(strings.NewReader(line))
+   token, err := dec.Token()
+   if err != nil {
+      return nil, err
+   }
+   if token != json.Delim('{') {
+      return nil, errors.New("rows must be JSON objects")
+   }
+
+   var keys []string
+   for dec.More() {
+      token, err := dec.Token()
+      if err != nil {
+         return nil, err
+      }
+      keys = append(keys, token.(string))
+      // skip the value
+      var value json.RawMessage
+      err = dec.Decode(&value)
+      if err != nil {
+         return nil, err
+      }
+   }
+
+   return keys, nil
+
+}
+
+
+// Apache Parquet, with keywords, units ("unit.<column>") & comments from its key-value metadata
+type parquetReader struct{}
+
+func (parquetReader) Read (data []byte) (Table, error) {
+
+   var t Table
+   pr, err := reader.NewParquetColumnReader(buffer.NewBufferFileFromBytes(data), 1)
+   if err != nil {
+      return t, err
+   }
+   defer pr.ReadStop()
+
+   units := make(map[string]string)
+   for _, kv := range pr.Footer.KeyValueMetadata {
+      value := ""
+      if kv.Value != nil {
+         value = *kv.Value
+      }
+      if strings.HasPrefix(kv.Key, "unit.") {
+         units[strings.TrimPrefix(kv.Key, "unit.")] = value
+      } else if kv.Key == "comments" {
+         t.Comments = strings.Split(value, "\n")
+      } else {
+         t.Keywords = append(t.Keywords, Keyword{Name: kv.Key, Value: value})
+      }
+   }
+
+   // leaves of the schema, after its root, are the columns. The reader renames them, so their
+   // names are taken as in the file
+   n := pr.GetNumRows()
+   sh := pr.SchemaHandler
+   for i := 1; i < len(sh.SchemaElements); i++ {
+      if sh.SchemaElements[i].GetNumChildren() > 0 {
+         continue
+      }
+      values, _, _, err := pr.ReadColumnByPath(sh.IndexMap[int32(i)], n)
+      if err != nil {
+         return t, err
+      }
+
+      c := Column{Name: sh.GetExName(i), Values: make([]float64, len(values)), Integer: true}
+      for k, v := range values {
+         x, integer := math.NaN(), false
+         switch v := v.(type) {
+         case float64:
+            x = v
+         case float32:
+            x = float64(v)
+         case int64:
+            x, integer = float64(v), true
+         case int32:
+            x, integer = float64(v), true
+         case bool:
+            x, integer = 0, true
+            if v {
+               x = 1
+            }
+         }
+         c.Values[k] = x
+         c.Integer = c.Integer && integer
+      }
+      t.Columns = append(t.Columns, c)
+   }
+   setUnits(&t, units)
+
+   return t, nil
+
+}
+
+
+// field of a NumPy array: name, kind (f, i, u or b), size in bytes and byte order
+type npyField struct {
+   name string
+   kind byte
+   size int
+   order binary.ByteOrder
+}
+
+
+// types of NumPy arrays as in descr, e.g. '<f8'
+var (
+   npyStructured = regexp.MustCompile(`\(\s*'([^']*)'\s*,\s*'([<>|=])([fiub])(\d+)'\s*\)`)
+   npyPlain = regexp.MustCompile(`'descr'\s*:\s*'([<>|=])([fiub])(\d+)'`)
+   npyShape = regexp.MustCompile(`'shape'\s*:\s*\(\s*(\d+)\s*,?\s*\)`)
+)
+
+
+// one-dimensional NumPy array of numbers, a column per field if it is structured
+type npyReader struct{}
+
+func (npyReader) Read (data []byte) (Table, error) {
+
+   columns, err := readNpy(data, "")
+
+   return Table{Columns: columns}, err
+
+}
+
+
+// NumPy archive of one-dimensional arrays, a column per array named as it is in the archive
+type npzReader struct{}
+
+func (npzReader) Read (data []byte) (Table, error) {
+
+   var t Table
+   zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+   if err != nil {
+      return t, err
+   }
+
+   for _, f := range zr.File {
+      r, err := f.Open()
+      if err != nil {
+         return t, err
+      }
+      array, err := ioutil.ReadAll(r)
+      r.Close()
+      if err != nil {
+         return t, err
+      }
+      columns, err := readNpy(array, strings.TrimSuffix(f.Name, ".npy"))
+      if err != nil {
+         return t, fmt.Errorf("%s: %v", f.Name, err)
+      }
+      t.Columns = append(t.Columns, columns...)
+   }
+   for _, c := range t.Columns {
+      if len(c.Values) != t.Rows() {
+         return t, errors.New("arrays of different length")
+      }
+   }
+
+   return t, nil
+
+}
+
+
+// columns of a .npy file (any format version), named after its fields or name if it has none
+func readNpy (data []byte, name string) ([]Column, error) {
+
+   if len(data) < 10 || string(data[:6]) != "\x93NUMPY" {
+      return nil, errors.New("not a .npy file")
+   }
+   // header length takes 2 bytes in version 1, 4 bytes after that
+   start := 10
+   size := int(binary.LittleEndian.Uint16(data[8:]))
+   if data[6] > 1 {
+      if len(data) < 12 {
+         return nil, errors.New("not a .npy file")
+      }
+      start = 12
+      size = int(binary.LittleEndian.Uint32(data[8:]))
+   }
+   if len(data) < start + size {
+      return nil, errors.New("truncated header")
+   }
+   header := string(data[start:start + size])
+   data = data[start + size:]
+
+   shape := npyShape.FindStringSubmatch(header)
+   if shape == nil {
+      return nil, errors.New("only one-dimensional arrays can be read")
+   }
+   n, _ := strconv.Atoi(shape[1])
+
+   var fields []npyField
+   if matches := npyStructured.FindAllStringSubmatch(header, -1); matches != nil {
+      for _, m := range matches {
+         fields = append(fields, newNpyField(m[1], m[2], m[3], m[4]))
+      }
+   } else if m := npyPlain.FindStringSubmatch(header); m != nil {
+      fields = append(fields, newNpyField(name, m[1], m[2], m[3]))
+   } else {
+      return nil, errors.New("only arrays of numbers can be read")
+   }
+
+   width := 0
+   for _, f := range fields {
+      width += f.size
+   }
+   if len(data) < n * width {
+      return nil, errors.New("truncated data")
+   }
+
+   columns := make([]Column, len(fields))
+   offset := 0
+   for j, f := range fields {
+      columns[j] = Column{Name: f.name, Values: make([]float64, n), Integer: f.kind != 'f'}
+      for k := 0; k < n; k++ {
+         x, err := npyValue(data[k * width + offset:], f)
+         if err != nil {
+            return nil, err
+         }
+         columns[j].Values[k] = x
+      }
+      offset += f.size
+   }
+
+   return columns, nil
+
+}
+
+
+func newNpyField (name string, order string, kind string, size string) npyField {
+
+   f := npyField{name: name, kind: kind[0], order: binary.LittleEndian}
+   f.size, _ = strconv.Atoi(size)
+   if order == ">" {
+      f.order = binary.BigEndian
+   }
+
+   return f
+
+}
+
+
+// value of a field of a NumPy array at the start of b
+func npyValue (b []byte, f npyField) (float64, error) {
+
+   switch {
+   case f.kind == 'f' && f.size == 8:
+      return math.Float64frombits(f.order.Uint64(b)), nil
+   case f.kind == 'f' && f.size == 4:
+      return float64(math.Float32frombits(f.order.Uint32(b))), nil
+   case f.kind == 'i' && f.size == 8:
+      return float64(int64(f.order.Uint64(b))), nil
+   case f.kind == 'i' && f.size == 4:
+      return float64(int32(f.order.Uint32(b))), nil
+   case f.kind == 'i' && f.size == 2:
+      return float64(int16(f.order.Uint16(b))), nil
+   case f.kind == 'i' && f.size == 1:
+      return float64(int8(b[0])), nil
+   case f.kind == 'u' && f.size == 8:
+      return float64(f.order.Uint64(b)), nil
+   case f.kind == 'u' && f.size == 4:
+      return float64(f.order.Uint32(b)), nil
+   case f.kind == 'u' && f.size == 2:
+      return float64(f.order.Uint16(b)), nil
+   case (f.kind == 'u' || f.kind == 'b') && f.size == 1:
+      return float64(b[0]), nil
+   }
+
+   return 0, fmt.Errorf("unknown type of field %s: %c%d", f.name, f.kind, f.size)
+
+}
+
+
+// first binary table (BINTABLE) of a FITS file. Units are read from TUNITn, HIERARCH cards are
+// keywords (in lowercase) and COMMENT cards are comments. Columns must be scalars: logical (L),
+// integers (B, I, J, K) or floats (E, D), with TSCALn & TZEROn applied if present
+type fitsReader struct{}
+
+func (fitsReader) Read (data []byte) (Table, error) {
+
+   var t Table
+   for len(data) > 0 {
+      h, size, err := readFitsHeader(data)
+      if err != nil {
+         return t, err
+      }
+      data = data[size:]
+
+      // size of the data of this HDU, padded to a whole block
+      bitpix, _ := strconv.Atoi(h.values["BITPIX"])
+      naxis, _ := strconv.Atoi(h.values["NAXIS"])
+      dataSize := 0
+      if naxis > 0 {
+         dataSize = abs(bitpix) / 8
+         for k := 1; k <= naxis; k++ {
+            n, _ := strconv.Atoi(h.values["NAXIS" + strconv.Itoa(k)])
+            dataSize *= n
+         }
+      }
+      pcount, _ := strconv.Atoi(h.values["PCOUNT"])
+      dataSize += pcount
+      if dataSize > len(data) {
+         return t, errors.New("truncated data")
+      }
+
+      if h.values["XTENSION"] == "BINTABLE" {
+         return h.table(data[:dataSize])
+      }
+      if dataSize % fitsBlock != 0 {
+         dataSize += fitsBlock - dataSize % fitsBlock
+      }
+      if dataSize > len(data) {
+         dataSize = len(data)
+      }
+      data = data[dataSize:]
+   }
+
+   return t, errors.New("no binary table found")
+
+}
+
+
+// cards of a FITS header: values of keywords, HIERARCH keywords (in order) and comments
+type fitsHeader struct {
+   values map[string]string
+   keywords []Keyword
+   comments []string
+}
+
+
+// header at the start of data, and its size in bytes (whole blocks)
+func readFitsHeader (data []byte) (fitsHeader, int, error) {
+
+   h := fitsHeader{values: make(map[string]string)}
+   for k := 0; k + fitsCard <= len(data); k += fitsCard {
+      card := string(data[k:k + fitsCard])
+      keyword := strings.TrimSpace(card[:8])
+
+      switch {
+      case keyword == "END":
+         size := k + fitsCard
+         if size % fitsBlock != 0 {
+            size += fitsBlock - size % fitsBlock
+         }
+         if size > len(data) {
+            size = len(data)
+         }
+         return h, size, nil
+      case keyword == "COMMENT":
+         h.comments = append(h.comments, strings.TrimRight(card[8:], " "))
+      case keyword == "HIERARCH":
+         l := strings.Index(card, "=")
+         if l < 0 {
+            continue
+         }
+         value, quoted := fitsValue(card[l+1:])
+         if !quoted && (value == "T" || value == "F") {
+            value = strconv.FormatBool(value == "T")
+         }
+         h.keywords = append(h.keywords, Keyword{Name: strings.ToLower(strings.TrimSpace(card[9:l])), Value: value})
+      case len(card) > 9 && card[8:10] == "= ":
+         value, _ := fitsValue(card[10:])
+         h.values[keyword] = value
+      }
+   }
+
+   return h, 0, errors.New("header without END")
+
+}
+
+
+// value of a card, without its comment. Strings lose their quotes (and trailing spaces)
+func fitsValue (s string) (string, bool) {
+
+   s = strings.TrimSpace(s)
+   if strings.HasPrefix(s, "'") {
+      var sb strings.Builder
+      for k := 1; k < len(s); k++ {
+         if s[k] == '\'' {
+            if k + 1 < len(s) && s[k+1] == '\'' {
+               sb.WriteByte('\'')
+               k++
+               continue
+            }
+            break
+         }
+         sb.WriteByte(s[k])
+      }
+      return strings.TrimRight(sb.String(), " "), true
+   }
+   if k := strings.Index(s, "/"); k >= 0 {
+      s = s[:k]
+   }
+
+   return strings.TrimSpace(s), false
+
+}
+
+
+// columns of a binary table from its header & data
+func (h fitsHeader) table (data []byte) (Table, error) {
+
+   t := Table{Name: strings.ToLower(h.values["EXTNAME"]), Keywords: h.keywords, Comments: h.comments}
+   width, _ := strconv.Atoi(h.values["NAXIS1"])
+   rows, _ := strconv.Atoi(h.values["NAXIS2"])
+   nFields, _ := strconv.Atoi(h.values["TFIELDS"])
+   if width * rows > len(data) {
+      return t, errors.New("truncated data")
+   }
+
+   tform := regexp.MustCompile(`^(\d*)([LXBIJKAEDCMPQ])`)
+   offset := 0
+   for j := 1; j <= nFields; j++ {
+      n := strconv.Itoa(j)
+      m := tform.FindStringSubmatch(h.values["TFORM" + n])
+      if m == nil {
+         return t, errors.New("unknown TFORM" + n + ": " + h.values["TFORM" + n])
+      }
+      repeat := 1
+      if m[1] != "" {
+         repeat, _ = strconv.Atoi(m[1])
+      }
+      size := map[string]int{"L": 1, "X": 1, "B": 1, "I": 2, "J": 4, "K": 8, "A": 1, "E": 4, "D": 8, "C": 8, "M": 16, "P": 8, "Q": 16}[m[2]]
+      if m[2] == "X" {
+         size, repeat = 1, (repeat + 7) / 8
+      }
+
+      // only scalar numbers are read, other columns are skipped
+      if repeat == 1 && strings.Contains("LBIJKED", m[2]) {
+         c := Column{Name: h.values["TTYPE" + n], Unit: h.values["TUNIT" + n], Values: make([]float64, rows), Integer: !strings.Contains("ED", m[2])}
+         scale, zero := 1.0, 0.0
+         if s, ok := h.values["TSCAL" + n]; ok {
+            scale, _ = strconv.ParseFloat(s, 64)
+         }
+         if z, ok := h.values["TZERO" + n]; ok {
+            zero, _ = strconv.ParseFloat(z, 64)
+         }
+         for k := 0; k < rows; k++ {
+            c.Values[k] = scale * fitsNumber(data[k * width + offset:], m[2]) + zero
+         }
+         c.Integer = c.Integer && scale == math.Trunc(scale) && zero == math.Trunc(zero)
+         t.Columns = append(t.Columns, c)
+      }
+      offset += repeat * size
+   }
+
+   return t, nil
+
+}
+
+
+// big-endian number of a FITS type at the start of b
+func fitsNumber (b []byte, kind string) float64 {
+
+   switch kind {
+   case "L":
+      if b[0] == 'T' {
+         return 1
+      }
+      return 0
+   case "B":
+      return float64(b[0])
+   case "I":
+      return float64(int16(binary.BigEndian.Uint16(b)))
+   case "J":
+      return float64(int32(binary.BigEndian.Uint32(b)))
+   case "K":
+      return float64(int64(binary.BigEndian.Uint64(b)))
+   case "E":
+      return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+   }
+
+   return math.Float64frombits(binary.BigEndian.Uint64(b))
+
+}
+
+
+func abs (n int) int {
+
+   if n < 0 {
+      return -n
+   }
+
+   return n
+
+}
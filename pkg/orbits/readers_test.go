@@ -0,0 +1,63 @@
+package orbits
+
+import (
+   "os"
+   "path/filepath"
+   "testing"
+)
+
+
+// keywords are read from # lines before the header and after the rows, as written by streaming
+// runs, in text & csv
+func TestReadTrailingKeywords (t *testing.T) {
+
+   tables := map[string]string{
+      "csv": "# timestamp: 2026-01-01T00:00:00Z\n# units: w=km/s\nw,theta\n1.5,0.5\n2.5,1\n# number_of_kicks: 2\n# bounded_fraction: 0.5\n",
+      "text": "# timestamp: 2026-01-01T00:00:00Z\n# units: w=km/s\nw theta\n1.5 0.5\n2.5 1\n# number_of_kicks: 2\n# bounded_fraction: 0.5\n",
+   }
+
+   for format, data := range tables {
+      tr, err := NewTableReader(format)
+      if err != nil {
+         t.Fatal(err)
+      }
+      table, err := tr.Read([]byte(data))
+      if err != nil {
+         t.Fatalf("%s: %v", format, err)
+      }
+      if table.Rows() != 2 {
+         t.Errorf("%s: got %d rows, want 2", format, table.Rows())
+      }
+      for name, want := range map[string]string{"timestamp": "2026-01-01T00:00:00Z", "number_of_kicks": "2", "bounded_fraction": "0.5"} {
+         if got, _ := table.Keyword(name); got != want {
+            t.Errorf("%s: keyword %s is %q, want %q", format, name, got, want)
+         }
+      }
+      if c, _ := table.Column("w"); c.Unit != "km/s" {
+         t.Errorf("%s: unit of w is %q, want km/s", format, c.Unit)
+      }
+   }
+
+}
+
+
+// grid matrices that are missing or not JSON are IOErrors
+func TestReadGridMatrixErrors (t *testing.T) {
+
+   dir := t.TempDir()
+   _, err := ReadGridMatrix(filepath.Join(dir, "missing.json"))
+   if e, ok := err.(*IOError); !ok || e.Op != "read" {
+      t.Errorf("missing grid matrix: got error %v, want an IOError on read", err)
+   }
+
+   filename := filepath.Join(dir, "grid_matrix.json")
+   err = os.WriteFile(filename, []byte("period: 1"), 0644)
+   if err != nil {
+      t.Fatal(err)
+   }
+   _, err = ReadGridMatrix(filename)
+   if e, ok := err.(*IOError); !ok || e.Op != "parse" {
+      t.Errorf("grid matrix that is not JSON: got error %v, want an IOError on parse", err)
+   }
+
+}
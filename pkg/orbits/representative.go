@@ -0,0 +1,238 @@
+package orbits
+
+import (
+   "math"
+
+   "github.com/asimazbunzel/go-orbits/pkg/io"
+   "github.com/asimazbunzel/go-orbits/pkg/units"
+
+   "golang.org/x/exp/rand"
+   "gonum.org/v1/gonum/floats"
+   "gonum.org/v1/gonum/stat"
+)
+
+
+// select NumberOfRepresentativeOrbits orbits that best represent the bounded binaries, using
+// weighted k-means (Lloyd's algorithm for optimal quantization) on log10(period), eccentricity
+// and, if RepresentativeUseVsys, systemic velocity. Each quantity is scaled by its standard
+// deviation. Each representative orbit is the weighted mean of the binaries closest to it
+// (geometric in period) and its probability is their share of the bounded binaries
+func (b *Binary) RepresentativeOrbits () error {
+
+   if b.LogLevel != "none" {
+      io.LogInfo("ORBITS - representative.go - RepresentativeOrbits", "selecting representative orbits")
+   }
+
+   nBounded := len(b.IndexBounded)
+   nClusters := b.NumberOfRepresentativeOrbits
+   if nClusters <= 0 {
+      return &ConfigError{Option: "number_of_representative_orbits", Message: "must be greater than 0"}
+   }
+   if nBounded < nClusters {
+      return &GridError{Message: "less bounded binaries than representative orbits"}
+   }
+
+   // representative orbits of previous calls are replaced
+   b.PeriodRepresentative, b.SeparationRepresentative, b.EccentricityRepresentative = nil, nil, nil
+   b.VsysRepresentative, b.ProbabilityRepresentative = nil, nil
+
+   // features of each binary, scaled to unit standard deviation
+   columns := [][]float64{make([]float64, nBounded), b.EccentricityBounded}
+   for k, p := range b.PeriodBounded {
+      columns[0][k] = math.Log10(p)
+   }
+   if b.RepresentativeUseVsys {
+      columns = append(columns, b.VsysBounded)
+   }
+   nDim := len(columns)
+   points := make([][]float64, nBounded)
+   for k := 0; k < nBounded; k++ {
+      points[k] = make([]float64, nDim)
+   }
+   for d, column := range columns {
+      sigma := stat.StdDev(column, b.WeightBounded)
+      if sigma == 0 || math.IsNaN(sigma) {
+         sigma = 1
+      }
+      for k, x := range column {
+         points[k][d] = x / sigma
+      }
+   }
+
+   centers := b.kMeansPlusPlus(points, nClusters)
+   labels := make([]int, nBounded)
+   for iter := 0; iter < b.RepresentativeMaxIterations; iter++ {
+
+      // assign each binary to its closest center
+      changed := false
+      for k, point := range points {
+         closest := nearestCenter(point, centers)
+         if closest != labels[k] {
+            changed = true
+            labels[k] = closest
+         }
+      }
+
+      // move centers to the weighted mean of their binaries
+      sums := make([][]float64, nClusters)
+      weights := make([]float64, nClusters)
+      counts := make([]int, nClusters)
+      for c := 0; c < nClusters; c++ {
+         sums[c] = make([]float64, nDim)
+      }
+      for k, point := range points {
+         floats.AddScaled(sums[labels[k]], b.WeightBounded[k], point)
+         weights[labels[k]] += b.WeightBounded[k]
+         if b.WeightBounded[k] > 0 {
+            counts[labels[k]]++
+         }
+      }
+
+      // empty clusters are seeded again with the binary farthest from its center, as k-means++
+      // would choose it, taken from a cluster that keeps other binaries
+      for c := 0; c < nClusters; c++ {
+         if counts[c] > 0 {
+            continue
+         }
+         k := b.farthestPoint(points, centers, labels, counts)
+         if k < 0 {
+            return &GridError{Message: "less bounded binaries with weight than representative orbits"}
+         }
+         w := b.WeightBounded[k]
+         floats.AddScaled(sums[labels[k]], -w, points[k])
+         weights[labels[k]] -= w
+         counts[labels[k]]--
+         labels[k] = c
+         floats.AddScaled(sums[c], w, points[k])
+         weights[c] += w
+         counts[c]++
+         changed = true
+      }
+
+      for c := 0; c < nClusters; c++ {
+         if weights[c] > 0 {
+            floats.ScaleTo(centers[c], 1.0 / weights[c], sums[c])
+         }
+      }
+
+      if !changed && iter > 0 {
+         break
+      }
+   }
+
+   // representative orbit & probability of each cluster, in physical units
+   totalWeight := floats.Sum(b.WeightBounded)
+   logP := make([]float64, nClusters)
+   e := make([]float64, nClusters)
+   vsys := make([]float64, nClusters)
+   weights := make([]float64, nClusters)
+   for k, c := range labels {
+      w := b.WeightBounded[k]
+      logP[c] += w * columns[0][k]
+      e[c] += w * b.EccentricityBounded[k]
+      vsys[c] += w * b.VsysBounded[k]
+      weights[c] += w
+   }
+
+   for c := 0; c < nClusters; c++ {
+      p := math.Pow(10.0, logP[c] / weights[c])
+      b.PeriodRepresentative = append(b.PeriodRepresentative, p)
+      b.SeparationRepresentative = append(b.SeparationRepresentative, float64(PtoA(units.Time(p), b.massCO(), b.mass2())))
+      b.EccentricityRepresentative = append(b.EccentricityRepresentative, e[c] / weights[c])
+      b.VsysRepresentative = append(b.VsysRepresentative, vsys[c] / weights[c])
+      b.ProbabilityRepresentative = append(b.ProbabilityRepresentative, weights[c] / totalWeight)
+   }
+
+   return nil
+
+}
+
+
+// initial centers for k-means, chosen with probability proportional to the weight of each point
+// times its squared distance to the closest center already chosen (k-means++). Random numbers
+// come from a stream seeded with Seed
+func (b *Binary) kMeansPlusPlus (points [][]float64, nClusters int) [][]float64 {
+
+   src := rand.New(rand.NewSource(b.Seed))
+
+   centers := make([][]float64, 0, nClusters)
+   distances := make([]float64, len(points))
+   for k, _ := range distances {
+      distances[k] = 1
+   }
+
+   for len(centers) < nClusters {
+      total := 0.0
+      for k, d := range distances {
+         total += b.WeightBounded[k] * d
+      }
+
+      // if all points are already centers, repeat the first one
+      chosen := 0
+      if total > 0 {
+         u := src.Float64() * total
+         cumsum := 0.0
+         for k, d := range distances {
+            cumsum += b.WeightBounded[k] * d
+            if cumsum >= u {
+               chosen = k
+               break
+            }
+         }
+      }
+
+      center := make([]float64, len(points[chosen]))
+      copy(center, points[chosen])
+      centers = append(centers, center)
+
+      for k, point := range points {
+         d := floats.Distance(point, center, 2)
+         if len(centers) == 1 || d * d < distances[k] {
+            distances[k] = d * d
+         }
+      }
+   }
+
+   return centers
+
+}
+
+
+// index of the point with weight farthest from the center of its cluster, among clusters with
+// more than one point with weight (counts), or -1 if there is none
+func (b *Binary) farthestPoint (points [][]float64, centers [][]float64, labels []int, counts []int) int {
+
+   farthest := -1
+   maxDistance := -1.0
+   for k, point := range points {
+      if b.WeightBounded[k] <= 0 || counts[labels[k]] < 2 {
+         continue
+      }
+      d := floats.Distance(point, centers[labels[k]], 2)
+      if d > maxDistance {
+         maxDistance = d
+         farthest = k
+      }
+   }
+
+   return farthest
+
+}
+
+
+// index of the center closest to a point
+func nearestCenter (point []float64, centers [][]float64) int {
+
+   closest := 0
+   minDistance := math.Inf(1)
+   for c, center := range centers {
+      d := floats.Distance(point, center, 2)
+      if d < minDistance {
+         minDistance = d
+         closest = c
+      }
+   }
+
+   return closest
+
+}
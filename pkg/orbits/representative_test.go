@@ -0,0 +1,52 @@
+package orbits
+
+import (
+   "math"
+   "testing"
+
+   "gonum.org/v1/gonum/floats"
+)
+
+
+// clusters left empty (here, by binaries that are all the same orbit) are seeded again, so there
+// are always as many representative orbits as asked, with probabilities that sum to 1
+func TestRepresentativeOrbitsEmptyClusters (t *testing.T) {
+
+   cfg := testConfig(0)
+   cfg.NumberOfRepresentativeOrbits, cfg.RepresentativeMaxIterations = 4, 20
+   b := NewBinary(cfg)
+   n := 50
+   b.IndexBounded = make([]int, n)
+   b.PeriodBounded, b.EccentricityBounded = make([]float64, n), make([]float64, n)
+   b.VsysBounded, b.WeightBounded = make([]float64, n), make([]float64, n)
+   for k := 0; k < n; k++ {
+      b.IndexBounded[k] = k
+      b.PeriodBounded[k], b.EccentricityBounded[k], b.WeightBounded[k] = 1e6, 0.3, 1
+   }
+
+   err := b.RepresentativeOrbits()
+   if err != nil {
+      t.Fatal(err)
+   }
+   if len(b.PeriodRepresentative) != 4 {
+      t.Fatalf("got %d representative orbits, want 4", len(b.PeriodRepresentative))
+   }
+   if total := floats.Sum(b.ProbabilityRepresentative); math.Abs(total - 1) > 1e-12 {
+      t.Errorf("probabilities of representative orbits sum to %f, want 1", total)
+   }
+   for c, p := range b.PeriodRepresentative {
+      if relativeDifference(p, 1e6) > 1e-12 {
+         t.Errorf("representative orbit %d: got period %e, want 1e6", c, p)
+      }
+   }
+
+   // binaries without weight cannot seed a cluster
+   for k := 3; k < n; k++ {
+      b.WeightBounded[k] = 0
+   }
+   err = b.RepresentativeOrbits()
+   if _, ok := err.(*GridError); !ok {
+      t.Errorf("3 binaries with weight for 4 orbits: got error %v, want a GridError", err)
+   }
+
+}
@@ -0,0 +1,328 @@
+package orbits
+
+import (
+   "context"
+)
+
+
+// natal kick: strength in km/s, direction (theta & phi) in radians in the frame of
+// OrbitsAfterKicks and weight (unity unless kicks were drawn with importance sampling)
+type Kick struct {
+   W float64
+   Theta float64
+   Phi float64
+   Weight float64
+}
+
+
+// orbit of a binary that remains bounded after a kick, with the index of its kick (KickID).
+// Separation in Rsun, period in days, systemic velocity in km/s, tilt in radians and time to
+// merge by emission of gravitational waves in years
+type Orbit struct {
+   KickID int
+   Kick
+   Separation float64
+   Period float64
+   Eccentricity float64
+   Vsys float64
+   Tilt float64
+   TimeGW float64
+}
+
+
+// cell of the grid of orbits above the minimum probability: its representative orbit, borders
+// and probability (with its confidence interval)
+type GridCell struct {
+   Period float64
+   Separation float64
+   Eccentricity float64
+   PeriodLower float64
+   PeriodUpper float64
+   EccentricityLower float64
+   EccentricityUpper float64
+   Probability float64
+   ProbabilityLower float64
+   ProbabilityUpper float64
+}
+
+
+// orbit that represents a share (Probability) of the bounded binaries
+type RepresentativeOrbit struct {
+   Period float64
+   Separation float64
+   Eccentricity float64
+   Vsys float64
+   Probability float64
+}
+
+
+// N-dimensional grid of bounded binaries over Axes, with probabilities in row-major order (last
+// axis changes fastest) and the 1D histogram of each axis (Marginals)
+type MultiGrid struct {
+   Axes []GridAxis
+   Edges [][]float64
+   Probability []float64
+   Marginals [][]float64
+}
+
+
+// outcome of the study of kicks of a binary, in astro units. Results that were not asked for in
+// its Config are empty
+type Result struct {
+   Config Config
+   Timestamp string
+   ConfigHash string
+
+   Kicks []Kick
+   Orbits []Orbit
+
+   BoundedFraction float64
+   BoundedFractionWilson [2]float64
+   BoundedFractionClopperPearson [2]float64
+   AchievedError float64
+
+   Grid []GridCell
+   PeriodBorders []float64
+   EccentricityBorders []float64
+   ProbabilityMatrix [][]float64
+   ProbabilityOutsideGrid float64
+   ProbabilityBelowThreshold float64
+
+   MultiGrid MultiGrid
+   Representatives []RepresentativeOrbit
+
+   KickThetaMap SurvivalMap
+   ThetaPhiMap SurvivalMap
+   ThetaBoundary []float64
+   MinKickBoundary []float64
+   MaxKickBoundary []float64
+}
+
+
+// study the kicks of a binary with options cfg, which is left unchanged: kicks, their orbits,
+// grid of orbits and, if asked in cfg, the multi-dimensional grid, representative orbits and
+// survival maps. Nothing is saved to files, except kicks & orbits of streaming runs (with empty
+// Kicks & Orbits in the Result). The run stops between steps if ctx is done. Runs that do not
+// converge return their Result together with a ConvergenceError
+func Run (ctx context.Context, cfg Config) (Result, error) {
+
+   b := NewBinary(cfg)
+   err := b.Run(ctx)
+   if _, ok := err.(*ConvergenceError); ok {
+      return b.Result(), err
+   }
+   if err != nil {
+      return Result{}, err
+   }
+
+   return b.Result(), nil
+
+}
+
+
+// every step of the study of kicks, replacing results of previous runs. Kicks are replayed
+// from a file, drawn until convergence or computed, as set in the config. Streaming runs keep
+// neither kicks nor orbits, which are written to their files (if asked) as they are made. Results
+// are left in astro units. A ConvergenceError is returned once every step is done
+func (b *Binary) Run (ctx context.Context) error {
+
+   // results are always left in astro units, even after an error
+   defer b.ConvertoAstro()
+
+   var unconverged error
+   err := b.checkCheckpointOptions()
+   if err == nil {
+      err = b.checkOutputFormats()
+   }
+   if err != nil {
+      return err
+   }
+
+   // batch by batch, without keeping kicks & orbits
+   if b.Streaming {
+      return b.runStreaming(ctx)
+   }
+
+   if b.ReplayKicks {
+      // kicks of a previous run (or another code) instead of new ones
+      err = b.LoadKicks(b.ReplayKicksFilename, b.ReplayKicksFormat)
+      if err == nil {
+         err = ctx.Err()
+      }
+      if err != nil {
+         return err
+      }
+      b.ConvertoCGS()
+      b.OrbitsAfterKicks()
+   } else if b.ConvergenceTarget == "" || b.ConvergenceTarget == "none" {
+      err = b.ComputeKicks()
+      if err == nil {
+         err = ctx.Err()
+      }
+      if err != nil {
+         return err
+      }
+      b.ConvertoCGS()
+      b.OrbitsAfterKicks()
+   } else {
+      // kicks and their orbit configurations until reaching the desired precision
+      err = b.KicksUntilConverged()
+      if _, ok := err.(*ConvergenceError); ok {
+         // the run goes on with the kicks drawn, the error is returned at the end
+         unconverged = err
+      } else if err != nil {
+         return err
+      }
+   }
+
+   // grid of orbital parameters, followed by the optional ones
+   steps := []func () error{b.GridOfOrbits}
+   if b.StoreMultiGrid {
+      steps = append(steps, b.MultiGridOfOrbits)
+   }
+   if b.StoreRepresentativeOrbits {
+      steps = append(steps, b.RepresentativeOrbits)
+   }
+   if b.StoreSurvivalMaps {
+      steps = append(steps, b.SurvivalMaps)
+   }
+   for _, step := range steps {
+      err = ctx.Err()
+      if err != nil {
+         return err
+      }
+      err = step()
+      if err != nil {
+         return err
+      }
+   }
+
+   return unconverged
+
+}
+
+
+// results as a Result that shares no slice with b. Must be called in astro units
+func (b *Binary) Result () Result {
+
+   r := Result{
+      Config: b.Config.clone(),
+      Timestamp: b.Timestamp,
+      ConfigHash: b.ConfigHash,
+      BoundedFraction: b.BoundedFraction(),
+      BoundedFractionWilson: b.BoundedFractionWilson,
+      BoundedFractionClopperPearson: b.BoundedFractionClopperPearson,
+      AchievedError: b.AchievedError,
+      PeriodBorders: copyFloats(b.PeriodBordersGrid),
+      EccentricityBorders: copyFloats(b.EccentricityBordersGrid),
+      ProbabilityMatrix: copyMatrix(b.ProbabilityMatrix),
+      ProbabilityOutsideGrid: b.ProbabilityOutsideGrid,
+      ProbabilityBelowThreshold: b.ProbabilityBelowThreshold,
+      MultiGrid: MultiGrid{
+         Axes: append([]GridAxis(nil), b.GridAxes...),
+         Edges: copyMatrix(b.MultiGridEdges),
+         Probability: copyFloats(b.MultiGridProbability),
+         Marginals: copyMatrix(b.MultiGridMarginals),
+      },
+      KickThetaMap: b.KickThetaMap.clone(),
+      ThetaPhiMap: b.ThetaPhiMap.clone(),
+      ThetaBoundary: copyFloats(b.ThetaBoundary),
+      MinKickBoundary: copyFloats(b.MinKickBoundary),
+      MaxKickBoundary: copyFloats(b.MaxKickBoundary),
+   }
+
+   r.Kicks = make([]Kick, len(b.W))
+   for k, _ := range b.W {
+      r.Kicks[k] = Kick{W: b.W[k], Theta: b.Theta[k], Phi: b.Phi[k], Weight: b.Weight[k]}
+   }
+
+   r.Orbits = make([]Orbit, len(b.IndexBounded))
+   for k, kb := range b.IndexBounded {
+      r.Orbits[k] = Orbit{
+         KickID: kb,
+         Kick: Kick{W: b.WBounded[k], Theta: b.ThetaBounded[k], Phi: b.PhiBounded[k], Weight: b.WeightBounded[k]},
+         Separation: b.SeparationBounded[k],
+         Period: b.PeriodBounded[k],
+         Eccentricity: b.EccentricityBounded[k],
+         Vsys: b.VsysBounded[k],
+         Tilt: b.TiltBounded[k],
+         TimeGW: b.TimeGWBounded[k],
+      }
+   }
+
+   r.Grid = make([]GridCell, len(b.PeriodGrid))
+   for k, _ := range b.PeriodGrid {
+      r.Grid[k] = GridCell{
+         Period: b.PeriodGrid[k],
+         Separation: b.SeparationGrid[k],
+         Eccentricity: b.EccentricityGrid[k],
+         PeriodLower: b.PeriodLowerGrid[k],
+         PeriodUpper: b.PeriodUpperGrid[k],
+         EccentricityLower: b.EccentricityLowerGrid[k],
+         EccentricityUpper: b.EccentricityUpperGrid[k],
+         Probability: b.ProbabilityGrid[k],
+         ProbabilityLower: b.ProbabilityLowerGrid[k],
+         ProbabilityUpper: b.ProbabilityUpperGrid[k],
+      }
+   }
+
+   r.Representatives = make([]RepresentativeOrbit, len(b.PeriodRepresentative))
+   for k, _ := range b.PeriodRepresentative {
+      r.Representatives[k] = RepresentativeOrbit{
+         Period: b.PeriodRepresentative[k],
+         Separation: b.SeparationRepresentative[k],
+         Eccentricity: b.EccentricityRepresentative[k],
+         Vsys: b.VsysRepresentative[k],
+         Probability: b.ProbabilityRepresentative[k],
+      }
+   }
+
+   return r
+
+}
+
+
+// copy of a survival map that shares no slice with it
+func (m SurvivalMap) clone () SurvivalMap {
+
+   c := SurvivalMap{
+      XEdges: copyFloats(m.XEdges),
+      YEdges: copyFloats(m.YEdges),
+      Fraction: copyMatrix(m.Fraction),
+      MeanSeparation: copyMatrix(m.MeanSeparation),
+      MeanEccentricity: copyMatrix(m.MeanEccentricity),
+   }
+   if m.Number != nil {
+      c.Number = make([][]int, len(m.Number))
+      for i, row := range m.Number {
+         c.Number[i] = append([]int(nil), row...)
+      }
+   }
+
+   return c
+
+}
+
+
+// copy of a slice, nil if empty
+func copyFloats (x []float64) []float64 {
+
+   return append([]float64(nil), x...)
+
+}
+
+
+// copy of a matrix, row by row
+func copyMatrix (x [][]float64) [][]float64 {
+
+   if x == nil {
+      return nil
+   }
+   c := make([][]float64, len(x))
+   for i, row := range x {
+      c[i] = copyFloats(row)
+   }
+
+   return c
+
+}
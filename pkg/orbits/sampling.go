@@ -0,0 +1,109 @@
+package orbits
+
+import (
+   "math"
+)
+
+
+// probability density of a Maxwellian distribution with dispersion sigma
+func maxwellPDF (w float64, sigma float64) float64 {
+
+   if w < 0 || sigma <= 0 {
+      return 0
+   }
+
+   return math.Sqrt(2.0/math.Pi) * math.Pow(w,2.0) / math.Pow(sigma,3.0) * math.Exp(-math.Pow(w,2.0) / (2.0 * math.Pow(sigma,2.0)))
+
+}
+
+
+// probability density of a uniform distribution between xmin & xmax
+func uniformPDF (x float64, xmin float64, xmax float64) float64 {
+
+   if x < xmin || x > xmax || xmax <= xmin {
+      return 0
+   }
+
+   return 1.0 / (xmax - xmin)
+
+}
+
+
+// probability density of the cosine of theta biased towards backward kicks (cos(theta) = -1)
+// q(mu) = kappa exp(-kappa mu) / (2 sinh(kappa)), for mu in [-1,1]. kappa = 0 is isotropic
+func backwardPDF (mu float64, kappa float64) float64 {
+
+   if mu < -1 || mu > 1 {
+      return 0
+   }
+
+   if kappa == 0 {
+      return 0.5
+   }
+
+   return kappa * math.Exp(-kappa * mu) / (2.0 * math.Sinh(kappa))
+
+}
+
+
+// inverse sampling of backwardPDF from a uniform deviate u in [0,1)
+func backwardRand (u float64, kappa float64) float64 {
+
+   if kappa == 0 {
+      return 2.0 * u - 1.0
+   }
+
+   return -math.Log(math.Exp(kappa) - 2.0 * u * math.Sinh(kappa)) / kappa
+
+}
+
+
+// probability density of the strength of a kick, before reduction by fallback, for one of the
+// available distributions
+func strengthPDF (w float64, distribution string, sigma float64, wMin float64, wMax float64) float64 {
+
+   switch distribution {
+   case "Maxwell":
+      return maxwellPDF(w, sigma)
+   case "Uniform":
+      return uniformPDF(w, wMin, wMax)
+   }
+
+   return 0
+
+}
+
+
+// probability density of the cosine of the polar angle of a kick for one of the available
+// directions
+func directionPDF (mu float64, direction string, kappa float64) float64 {
+
+   switch direction {
+   case "Uniform":
+      return backwardPDF(mu, 0)
+   case "Backward":
+      return backwardPDF(mu, kappa)
+   }
+
+   return 0
+
+}
+
+
+// importance weight of a kick drawn from the proposal distributions instead of the target ones
+// set in the configuration. w is the strength of the kick before any reduction by fallback, in
+// the same units as the config options (km/s)
+func (b *Binary) importanceWeight (w float64, theta float64) float64 {
+
+   mu := math.Cos(theta)
+
+   q := strengthPDF(w, b.ProposalKickStrengthDistribution, b.ProposalSigmaStrength, b.ProposalMinKickStrength, b.ProposalMaxKickStrength) * directionPDF(mu, b.ProposalKickDirection, b.ProposalBackwardBias)
+   if q == 0 {
+      return 0
+   }
+
+   p := strengthPDF(w, b.KickStrengthDistribution, b.SigmaStrength, b.MinKickStrength, b.MaxKickStrength) * directionPDF(mu, b.KickDirection, 0)
+
+   return p / q
+
+}
@@ -0,0 +1,44 @@
+package orbits
+
+import (
+   "context"
+   "math"
+   "testing"
+)
+
+
+// the fraction of bounded binaries with importance weights matches that of plain sampling,
+// within their errors
+func TestImportanceSamplingBoundedFraction (t *testing.T) {
+
+   cfg := testConfig(50000)
+   plain := NewBinary(cfg)
+   err := plain.Run(context.Background())
+   if err != nil {
+      t.Fatal(err)
+   }
+
+   cfg.Sampling = "importance"
+   cfg.ProposalKickStrengthDistribution, cfg.ProposalSigmaStrength = "Maxwell", 400
+   cfg.ProposalKickDirection, cfg.ProposalBackwardBias = "Backward", 1
+   cfg.Seed = 2000
+   weighted := NewBinary(cfg)
+   err = weighted.Run(context.Background())
+   if err != nil {
+      t.Fatal(err)
+   }
+
+   if weighted.effectiveNumberOfKicks() >= 0.99 * 50000 {
+      t.Fatalf("effective number of kicks with importance sampling: got %f, want weights that are not equal", weighted.effectiveNumberOfKicks())
+   }
+
+   f, fWeighted := plain.BoundedFraction(), weighted.BoundedFraction()
+   sigma := math.Hypot(plain.BoundedFractionError(), weighted.BoundedFractionError())
+   if math.Abs(f - fWeighted) > 4 * sigma {
+      t.Errorf("bounded fraction: got %f with importance sampling and %f without, more than 4 sigma (%f) apart", fWeighted, f, sigma)
+   }
+   if weighted.BoundedFractionError() <= 0 || weighted.BoundedFractionError() > 0.05 {
+      t.Errorf("error of the bounded fraction with importance sampling: got %f", weighted.BoundedFractionError())
+   }
+
+}
@@ -0,0 +1,570 @@
+package orbits
+
+import (
+   "bufio"
+   "context"
+   "fmt"
+   "os"
+   "strconv"
+
+   "github.com/asimazbunzel/go-orbits/pkg/io"
+
+   "gonum.org/v1/gonum/floats"
+)
+
+
+// default number of kicks of each batch of a streaming run
+const streamBatch = 16 * kickChunk
+
+
+// running totals of a streaming run: number & weights of kicks and bounded binaries, and sketches
+// of the period & eccentricity of bounded binaries for the quantiles of the grid
+type streamTotals struct {
+   kicks int
+   bounded int
+   weight float64
+   weight2 float64
+   weightBounded float64
+   weightBounded2 float64
+   period *TDigest
+   eccentricity *TDigest
+}
+
+
+// empty totals of a streaming run
+func newStreamTotals () *streamTotals {
+
+   return &streamTotals{period: NewTDigest(tdigestCompression), eccentricity: NewTDigest(tdigestCompression)}
+
+}
+
+
+// add the kicks & bounded binaries of a batch
+func (t *streamTotals) add (part *Binary) {
+
+   t.kicks += len(part.W)
+   t.bounded += len(part.IndexBounded)
+   t.weight += floats.Sum(part.Weight)
+   t.weight2 += floats.Dot(part.Weight, part.Weight)
+   t.weightBounded += floats.Sum(part.WeightBounded)
+   t.weightBounded2 += floats.Dot(part.WeightBounded, part.WeightBounded)
+   for k, w := range part.WeightBounded {
+      t.period.Add(part.PeriodBounded[k], w)
+      t.eccentricity.Add(part.EccentricityBounded[k], w)
+   }
+
+}
+
+
+// number of kicks of each batch: StreamBatchSize (or its default) rounded up to whole chunks, so
+// that batches draw the same kicks as a run that keeps every kick in memory
+func (b *Binary) streamBatchSize () int {
+
+   n := b.StreamBatchSize
+   if n <= 0 {
+      n = streamBatch
+   }
+
+   return numberOfChunks(n) * kickChunk
+
+}
+
+
+// check that the options of the config can be used in a streaming run, before any kick is made.
+// Outputs that need every kick or bounded binary at once are not available
+func (b *Binary) checkStreamOptions () error {
+
+   if b.NumberOfCases <= 0 {
+      return &ConfigError{Option: "number_of_cases", Message: "number of cases must be greater than 0 in streaming runs"}
+   }
+   if b.StreamBatchSize < 0 {
+      return &ConfigError{Option: "stream_batch_size", Message: "size of batches cannot be negative"}
+   }
+   if b.ReplayKicks {
+      return &ConfigError{Option: "replay_kicks", Message: "kicks cannot be replayed in streaming runs"}
+   }
+   if b.ConvergenceTarget != "" && b.ConvergenceTarget != "none" {
+      return &ConfigError{Option: "convergence_target", Message: "convergence control is not available in streaming runs"}
+   }
+   if b.Sampling == "quadrature" {
+      return &ConfigError{Option: "sampling", Message: "streaming runs need random kicks, not a quadrature grid"}
+   }
+   _, err := b.kickSampler()
+   if err != nil {
+      return err
+   }
+
+   err = b.checkGridOptions()
+   if err != nil {
+      return err
+   }
+   if b.GridMethod != "" && b.GridMethod != "histogram" {
+      return &ConfigError{Option: "grid_method", Message: "only histogram grids can be made in streaming runs"}
+   }
+   if b.GridIntervalMethod == "bootstrap" {
+      return &ConfigError{Option: "grid_interval_method", Message: "bootstrap intervals are not available in streaming runs, use multinomial"}
+   }
+
+   unavailable := []struct {
+      option string
+      store bool
+   }{
+      {"save_multidim_grid", b.StoreMultiGrid},
+      {"save_representative_orbits", b.StoreRepresentativeOrbits},
+      {"save_survival_maps", b.StoreSurvivalMaps},
+      {"save_database", b.StoreDatabase},
+   }
+   for _, u := range unavailable {
+      if u.store {
+         return &ConfigError{Option: u.option, Message: "needs every bounded binary in memory, not available in streaming runs"}
+      }
+   }
+
+   formats := []struct {
+      option string
+      format string
+      store bool
+   }{
+      {"kicks_format", b.KicksFormat, b.StoreKicks},
+      {"bounded_orbits_format", b.BoundedOrbitsFormat, b.StoreOrbits},
+   }
+   for _, f := range formats {
+      if !f.store {
+         continue
+      }
+      switch f.format {
+      case "", "text", "csv", "jsonl":
+      default:
+         return &ConfigError{Option: f.option, Message: "unknown value \"" + f.format + "\" for streaming runs, options are: text, csv, jsonl"}
+      }
+   }
+
+   return nil
+
+}
+
+
+// kicks of a batch, from index first, and their orbits. Kicks are left in km/s and orbits in CGS
+func (b *Binary) solveBatch (first int, n int) (Binary, error) {
+
+   part := Binary{Config: b.Config, firstKick: first}
+   part.LogLevel = "none"
+
+   err := part.drawKicks(n)
+   if err != nil {
+      return part, err
+   }
+   part.ConvertoCGS()
+   part.solveOrbits(0, n)
+
+   return part, nil
+
+}
+
+
+// call f for every batch of kicks, solved, in the order of their kicks and starting at kick from
+// (the first of a batch). It stops at the first error, or if ctx is done
+func (b *Binary) forEachBatch (ctx context.Context, from int, f func (part *Binary) error) error {
+
+   size := b.streamBatchSize()
+   nBatches := (b.NumberOfCases + size - 1) / size
+   for first := from; first < b.NumberOfCases; first += size {
+      err := ctx.Err()
+      if err != nil {
+         return err
+      }
+
+      n := size
+      if first + n > b.NumberOfCases {
+         n = b.NumberOfCases - first
+      }
+      part, err := b.solveBatch(first, n)
+      if err != nil {
+         return err
+      }
+      err = f(&part)
+      if err != nil {
+         return err
+      }
+
+      if b.LogLevel == "debug" {
+         msg := "batch " + strconv.Itoa(first / size + 1) + "/" + strconv.Itoa(nBatches) + " done"
+         io.LogInfo("ORBITS - stream.go - forEachBatch", msg)
+      }
+   }
+
+   return nil
+
+}
+
+
+// add the bounded binaries of a batch (in CGS) to a weighted histogram of period & eccentricity
+func addToHistogram (histogram [][]float64, part *Binary, pBorders []float64, eBorders []float64) {
+
+   for k, w := range part.WeightBounded {
+      i := cellIndex(part.EccentricityBounded[k], eBorders)
+      j := cellIndex(part.PeriodBounded[k], pBorders)
+      if i >= 0 && j >= 0 {
+         histogram[i][j] += w
+      }
+   }
+
+}
+
+
+// study of kicks in batches, keeping in memory only one batch, running totals and the histogram
+// of the grid of orbits. Kicks & bounded orbits are written to their files batch by batch, if they
+// are to be saved. Grid borders given by quantiles come from t-digest sketches, then a second pass
+// draws the same kicks again to fill the histogram. If set, checkpoints are written along the way
+// and a run goes on from the one loaded with LoadCheckpoint. Must be called in astro units
+func (b *Binary) runStreaming (ctx context.Context) error {
+
+   err := b.checkStreamOptions()
+   if err != nil {
+      return err
+   }
+
+   if b.LogLevel != "none" {
+      msg := "streaming " + strconv.Itoa(b.NumberOfCases) + " kicks in batches of " + strconv.Itoa(b.streamBatchSize())
+      io.LogInfo("ORBITS - stream.go - runStreaming", msg)
+   }
+
+   resume := b.resume
+   b.Reset()
+   b.totals = newStreamTotals()
+   b.ConvertoCGS()
+
+   s := streamState{pass: 1}
+   if resume != nil {
+      s = b.restoreCheckpoint(resume)
+      if b.LogLevel != "none" {
+         msg := "resuming pass " + strconv.Itoa(s.pass) + " from kick " + strconv.Itoa(s.next)
+         io.LogInfo("ORBITS - stream.go - runStreaming", msg)
+      }
+   }
+
+   if s.pass == 1 {
+      // outputs are written while kicks are made, with keywords known at the start of the run
+      var kicks, orbits *tableStream
+      if b.StoreKicks {
+         if resume != nil {
+            kicks, err = openTableStream(b.KicksFilename, b.KicksFormat, b.streamHeader(b.KicksTable()), resume.KicksOffset)
+         } else {
+            kicks, err = createTableStream(b.KicksFilename, b.KicksFormat, b.streamHeader(b.KicksTable()))
+         }
+         if err != nil {
+            return err
+         }
+         defer kicks.file.Close()
+      }
+      if b.StoreOrbits {
+         if resume != nil {
+            orbits, err = openTableStream(b.BoundedBinariesFilename, b.BoundedOrbitsFormat, b.streamHeader(b.BoundedOrbitsTable()), resume.OrbitsOffset)
+         } else {
+            orbits, err = createTableStream(b.BoundedBinariesFilename, b.BoundedOrbitsFormat, b.streamHeader(b.BoundedOrbitsTable()))
+         }
+         if err != nil {
+            return err
+         }
+         defer orbits.file.Close()
+      }
+
+      // with borders set by the config, the histogram is filled in the same pass
+      fixed := b.fixedGridBorders()
+      if fixed && s.histogram == nil {
+         s.pBorders, s.eBorders, err = b.gridBorders()
+         if err != nil {
+            return err
+         }
+         s.histogram = newMatrix(len(s.eBorders) - 1, len(s.pBorders) - 1)
+      }
+
+      err = b.forEachBatch(ctx, s.next, func (part *Binary) error {
+         b.totals.add(part)
+         if fixed {
+            addToHistogram(s.histogram, part, s.pBorders, s.eBorders)
+         }
+         part.ConvertoAstro()
+         if kicks != nil {
+            err := kicks.append(part.KicksTable())
+            if err != nil {
+               return err
+            }
+         }
+         if orbits != nil {
+            err := orbits.append(part.BoundedOrbitsTable())
+            if err != nil {
+               return err
+            }
+         }
+         s.next = part.firstKick + len(part.W)
+         if b.checkpointDue(s.next) {
+            return b.saveCheckpoint(s, kicks, orbits)
+         }
+         return nil
+      })
+      if err != nil {
+         return b.interrupted(ctx, err, s, kicks, orbits)
+      }
+
+      b.boundedFractionIntervals()
+      if b.LogLevel == "info" || b.LogLevel == "debug" {
+         b.printSummary()
+      }
+
+      // summary of the kicks goes at the end of the outputs
+      if kicks != nil {
+         err = kicks.close(b.summaryKeywords())
+         if err != nil {
+            return err
+         }
+      }
+      if orbits != nil {
+         err = orbits.close(b.summaryKeywords())
+         if err != nil {
+            return err
+         }
+      }
+
+      if b.LogLevel != "none" {
+         msg := "calculating grid of orbits for: " + strconv.Itoa(b.numberOfBounded()) + " cases"
+         io.LogInfo("ORBITS - stream.go - runStreaming", msg)
+      }
+      if b.numberOfBounded() == 0 {
+         return &GridError{Message: "no bounded binaries to make a grid of orbits"}
+      }
+
+      // otherwise, borders come from the sketches and the histogram from a second pass
+      s.pass, s.next = 2, b.NumberOfCases
+      if !fixed {
+         s.pBorders, s.eBorders, err = b.gridBorders()
+         if err != nil {
+            return err
+         }
+         s.histogram = newMatrix(len(s.eBorders) - 1, len(s.pBorders) - 1)
+         s.next = 0
+      }
+      if b.CheckpointFilename != "" {
+         err = b.saveCheckpoint(s, nil, nil)
+         if err != nil {
+            return err
+         }
+      }
+   } else {
+      b.boundedFractionIntervals()
+      if b.LogLevel == "info" || b.LogLevel == "debug" {
+         b.printSummary()
+      }
+   }
+
+   if s.next < b.NumberOfCases {
+      if b.LogLevel != "none" {
+         io.LogInfo("ORBITS - stream.go - runStreaming", "second pass over kicks to fill the grid")
+      }
+      err = b.forEachBatch(ctx, s.next, func (part *Binary) error {
+         addToHistogram(s.histogram, part, s.pBorders, s.eBorders)
+         s.next = part.firstKick + len(part.W)
+         if b.checkpointDue(s.next) {
+            return b.saveCheckpoint(s, nil, nil)
+         }
+         return nil
+      })
+      if err != nil {
+         return b.interrupted(ctx, err, s, nil, nil)
+      }
+   }
+
+   b.streamGrid(s.pBorders, s.eBorders, s.histogram)
+
+   // the run is complete, there is nothing left to resume
+   if b.CheckpointFilename != "" {
+      err = os.Remove(b.CheckpointFilename)
+      if err != nil && !os.IsNotExist(err) {
+         return &IOError{Filename: b.CheckpointFilename, Op: "remove", Err: err}
+      }
+   }
+
+   return nil
+
+}
+
+
+// grid of orbits of a streaming run from the weights of bounded binaries in each cell of the grid
+func (b *Binary) streamGrid (pBorders []float64, eBorders []float64, histogram [][]float64) {
+
+   b.resetGrid()
+   b.printGridLimits(pBorders, eBorders)
+
+   // weights in each cell, normalized by the weight of all bounded binaries
+   for i, _ := range histogram {
+      floats.Scale(1.0 / b.totals.weightBounded, histogram[i])
+   }
+
+   b.PeriodBordersGrid = pBorders
+   b.EccentricityBordersGrid = eBorders
+   b.finishGrid(b.gridFromProbabilities(pBorders, eBorders, histogram))
+
+}
+
+
+// matrix of zeros with nRows rows & nCols columns
+func newMatrix (nRows int, nCols int) [][]float64 {
+
+   m := make([][]float64, nRows)
+   for i, _ := range m {
+      m[i] = make([]float64, nCols)
+   }
+
+   return m
+
+}
+
+
+// header of a table of a streaming run: its columns, with the keywords known before any kick
+func (b *Binary) streamHeader (t Table) Table {
+
+   t.Keywords = append(b.runKeywords(), b.ConfigKeywords()...)
+
+   return t
+
+}
+
+
+// table written to a file in parts: a header, rows of each batch and, at the end, the keywords
+// only known once every row is written
+type tableStream struct {
+   filename string
+   format string
+   header Table
+   file *os.File
+   buf *bufio.Writer
+   tw RowWriter
+}
+
+
+// create filename and write the header of a table in format
+func createTableStream (filename string, format string, header Table) (*tableStream, error) {
+
+   tw, err := NewTableWriter(format)
+   if err != nil {
+      return nil, &ConfigError{Message: err.Error()}
+   }
+   rw, ok := tw.(RowWriter)
+   if !ok {
+      return nil, &ConfigError{Message: "format " + format + " cannot be written in parts"}
+   }
+
+   f, err := os.Create(filename)
+   if err != nil {
+      return nil, &IOError{Filename: filename, Op: "create", Err: err}
+   }
+
+   s := &tableStream{filename: filename, format: format, header: header, file: f, buf: bufio.NewWriter(f), tw: rw}
+   err = rw.WriteHeader(s.buf, header)
+   if err != nil {
+      f.Close()
+      return nil, &IOError{Filename: filename, Op: "write", Err: err}
+   }
+
+   return s, nil
+
+}
+
+
+// open filename, written up to offset (in bytes) by a previous run, to write the rest of its rows.
+// Anything after offset is removed
+func openTableStream (filename string, format string, header Table, offset int64) (*tableStream, error) {
+
+   tw, err := NewTableWriter(format)
+   if err != nil {
+      return nil, &ConfigError{Message: err.Error()}
+   }
+   rw, ok := tw.(RowWriter)
+   if !ok {
+      return nil, &ConfigError{Message: "format " + format + " cannot be written in parts"}
+   }
+
+   f, err := os.OpenFile(filename, os.O_WRONLY, 0)
+   if err != nil {
+      return nil, &IOError{Filename: filename, Op: "open", Err: err}
+   }
+   err = f.Truncate(offset)
+   if err == nil {
+      _, err = f.Seek(offset, 0)
+   }
+   if err != nil {
+      f.Close()
+      return nil, &IOError{Filename: filename, Op: "truncate", Err: err}
+   }
+
+   return &tableStream{filename: filename, format: format, header: header, file: f, buf: bufio.NewWriter(f), tw: rw}, nil
+
+}
+
+
+// write the rows of t
+func (s *tableStream) append (t Table) error {
+
+   err := s.tw.WriteRows(s.buf, t)
+   if err != nil {
+      return &IOError{Filename: s.filename, Op: "write", Err: err}
+   }
+
+   return nil
+
+}
+
+
+// flush rows to disk, returning the number of bytes written to the file
+func (s *tableStream) sync () (int64, error) {
+
+   err := s.buf.Flush()
+   if err == nil {
+      err = s.file.Sync()
+   }
+   if err != nil {
+      return 0, &IOError{Filename: s.filename, Op: "write", Err: err}
+   }
+
+   offset, err := s.file.Seek(0, 1)
+   if err != nil {
+      return 0, &IOError{Filename: s.filename, Op: "seek", Err: err}
+   }
+
+   return offset, nil
+
+}
+
+
+// write keywords after the rows (as comments), or with the rest of the metadata in the sidecar
+// file for formats that cannot hold them, and close the file
+func (s *tableStream) close (keywords []Keyword) error {
+
+   var err error
+   if embedsMetadata(s.format) {
+      for _, kw := range keywords {
+         _, err = fmt.Fprintf(s.buf, "# %s: %s\n", kw.Name, kw.Value)
+         if err != nil {
+            break
+         }
+      }
+   }
+   if err == nil {
+      err = s.buf.Flush()
+   }
+   if err == nil {
+      err = s.file.Close()
+   }
+   if err != nil {
+      return &IOError{Filename: s.filename, Op: "write", Err: err}
+   }
+
+   if !embedsMetadata(s.format) {
+      t := s.header
+      t.Keywords = append(append([]Keyword{}, t.Keywords...), keywords...)
+      return writeManifest(s.filename, s.format, t)
+   }
+
+   return nil
+
+}
@@ -0,0 +1,167 @@
+package orbits
+
+import (
+   "math"
+
+   "github.com/asimazbunzel/go-orbits/pkg/io"
+   "github.com/asimazbunzel/go-orbits/pkg/units"
+)
+
+
+// map of the outcome of kicks binned in two of their parameters (x, y). Rows are bins in y and
+// columns bins in x. Fraction is the (weighted) fraction of kicks that leave the binary bounded,
+// while MeanSeparation & MeanEccentricity are (weighted) means over the bounded binaries only
+type SurvivalMap struct {
+   XEdges []float64
+   YEdges []float64
+   Number [][]int
+   Fraction [][]float64
+   MeanSeparation [][]float64
+   MeanEccentricity [][]float64
+}
+
+
+// bin all kicks by (w, theta) and (theta, phi) and compute the analytic boundaries in w of the
+// region of bounded binaries for each theta
+func (b *Binary) SurvivalMaps () error {
+
+   if b.LogLevel != "none" {
+      io.LogInfo("ORBITS - survival.go - SurvivalMaps", "calculating survival maps in kick space")
+   }
+
+   if b.SurvivalMapKickBins <= 0 || b.SurvivalMapThetaBins <= 0 || b.SurvivalMapPhiBins <= 0 {
+      return &ConfigError{Option: "survival_map_kick_bins", Message: "number of bins of survival maps (of kicks, theta & phi) must be greater than 0"}
+   }
+
+   // separation & eccentricity for every kick, only used when bounded
+   bounded := make([]bool, b.NumberOfCases)
+   separation := make([]float64, b.NumberOfCases)
+   eccentricity := make([]float64, b.NumberOfCases)
+   for k, kb := range b.IndexBounded {
+      bounded[kb] = true
+      separation[kb] = b.SeparationBounded[k]
+      eccentricity[kb] = b.EccentricityBounded[k]
+   }
+
+   wMax := 0.0
+   for _, w := range b.W {
+      wMax = math.Max(wMax, w)
+   }
+
+   // number of bins are already checked, so edges cannot fail
+   wEdges, _ := LinSpace(0, wMax, b.SurvivalMapKickBins + 1)
+   thetaEdges, _ := LinSpace(0, math.Pi, b.SurvivalMapThetaBins + 1)
+   phiEdges, _ := LinSpace(b.MinPhi * math.Pi, b.MaxPhi * math.Pi, b.SurvivalMapPhiBins + 1)
+
+   b.KickThetaMap = survivalMap(b.W, b.Theta, wEdges, thetaEdges, b.Weight, bounded, separation, eccentricity)
+   b.ThetaPhiMap = survivalMap(b.Theta, b.Phi, thetaEdges, phiEdges, b.Weight, bounded, separation, eccentricity)
+
+   // analytic boundaries at the same resolution of the maps in theta
+   b.ThetaBoundary = make([]float64, b.SurvivalMapThetaBins + 1)
+   b.MinKickBoundary = make([]float64, b.SurvivalMapThetaBins + 1)
+   b.MaxKickBoundary = make([]float64, b.SurvivalMapThetaBins + 1)
+   for k, theta := range thetaEdges {
+      b.ThetaBoundary[k] = theta
+      b.MinKickBoundary[k], b.MaxKickBoundary[k] = b.kickBoundary(theta)
+   }
+
+   return nil
+
+}
+
+
+// weighted survival map of kicks with parameters (x, y) in a grid with borders xEdges & yEdges
+func survivalMap (x []float64, y []float64, xEdges []float64, yEdges []float64, weight []float64, bounded []bool, separation []float64, eccentricity []float64) SurvivalMap {
+
+   nRows := len(yEdges) - 1
+   nCols := len(xEdges) - 1
+
+   m := SurvivalMap{XEdges: xEdges, YEdges: yEdges}
+   m.Number = make([][]int, nRows)
+   m.Fraction = make([][]float64, nRows)
+   m.MeanSeparation = make([][]float64, nRows)
+   m.MeanEccentricity = make([][]float64, nRows)
+   total := make([][]float64, nRows)
+   for i := 0; i < nRows; i++ {
+      m.Number[i] = make([]int, nCols)
+      m.Fraction[i] = make([]float64, nCols)
+      m.MeanSeparation[i] = make([]float64, nCols)
+      m.MeanEccentricity[i] = make([]float64, nCols)
+      total[i] = make([]float64, nCols)
+   }
+
+   for k, _ := range x {
+      i := binIndex(y[k], yEdges)
+      j := binIndex(x[k], xEdges)
+      if i < 0 || j < 0 {
+         continue
+      }
+      m.Number[i][j]++
+      total[i][j] += weight[k]
+      if bounded[k] {
+         m.Fraction[i][j] += weight[k]
+         m.MeanSeparation[i][j] += weight[k] * separation[k]
+         m.MeanEccentricity[i][j] += weight[k] * eccentricity[k]
+      }
+   }
+
+   for i := 0; i < nRows; i++ {
+      for j := 0; j < nCols; j++ {
+         if m.Fraction[i][j] > 0 {
+            m.MeanSeparation[i][j] /= m.Fraction[i][j]
+            m.MeanEccentricity[i][j] /= m.Fraction[i][j]
+         } else {
+            m.MeanSeparation[i][j] = math.NaN()
+            m.MeanEccentricity[i][j] = math.NaN()
+         }
+         if total[i][j] > 0 {
+            m.Fraction[i][j] /= total[i][j]
+         } else {
+            m.Fraction[i][j] = math.NaN()
+         }
+      }
+   }
+
+   return m
+
+}
+
+
+// index of the bin of x in a grid with borders edges. Unlike cellIndex, the last border is
+// included in the last bin
+func binIndex (x float64, edges []float64) int {
+
+   n := len(edges) - 1
+   if x == edges[n] {
+      return n - 1
+   }
+
+   return cellIndex(x, edges)
+
+}
+
+
+// range of kick strengths, at a given theta, that leave the binary bounded. From eqs (3) & (4)
+// of Kalogera 1996, the binary is bounded when w^2 + 2 w vPre cos(theta) + vPre^2 < vEsc^2, with
+// vEsc^2 = 2 G (MCO + M2) / a. Whenever vEsc > vPre, the minimum is 0 and the maximum is the
+// minimum kick that disrupts the binary. If no kick leaves the binary bounded, both are NaN
+func (b *Binary) kickBoundary (theta float64) (float64, float64) {
+
+   vPre := float64(OrbitalVelocity(b.separation(), b.mass1(), b.mass2()))
+   vEsc2 := 2.0 * units.G * float64(b.massCO() + b.mass2()) / float64(b.separation())
+
+   mu := math.Cos(theta)
+   d := vEsc2 - math.Pow(vPre,2.0) * (1.0 - math.Pow(mu,2.0))
+   if d < 0 {
+      return math.NaN(), math.NaN()
+   }
+
+   wUpper := -vPre * mu + math.Sqrt(d)
+   if wUpper <= 0 {
+      return math.NaN(), math.NaN()
+   }
+   wLower := math.Max(0, -vPre * mu - math.Sqrt(d))
+
+   return wLower, wUpper
+
+}
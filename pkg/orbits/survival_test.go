@@ -0,0 +1,55 @@
+package orbits
+
+import (
+   "math"
+   "testing"
+)
+
+
+// kicks just inside the analytic boundary leave the binary bounded, whatever phi, and those just
+// outside disrupt it, for a binary that loses less than half of its mass and one that loses more
+func TestKickBoundarySimulated (t *testing.T) {
+
+   binaries := []Config{
+      {M1: 8.35, M2: 32.6, MCO: 1.66, Separation: 73.6, LogLevel: "none"},
+      {M1: 10, M2: 1, MCO: 1.4, Separation: 20, LogLevel: "none"},
+   }
+
+   for _, cfg := range binaries {
+      b := NewBinary(cfg)
+      for theta := 0.05; theta < math.Pi; theta += 0.2 {
+         wLower, wUpper := b.kickBoundary(theta)
+         if math.IsNaN(wUpper) {
+            continue
+         }
+
+         // kicks & whether each must leave the binary bounded
+         var bounded []bool
+         b.W, b.Theta, b.Phi, b.Weight = nil, nil, nil, nil
+         for _, phi := range []float64{0, 1, 2.5, 4} {
+            kicks := map[float64]bool{wUpper * 0.999: true, wUpper * 1.001: false}
+            if wLower > 0 {
+               kicks[wLower * 1.001] = true
+               kicks[wLower * 0.999] = false
+            }
+            for w, inside := range kicks {
+               b.W, b.Theta, b.Phi, b.Weight = append(b.W, w), append(b.Theta, theta), append(b.Phi, phi), append(b.Weight, 1)
+               bounded = append(bounded, inside)
+            }
+         }
+
+         b.resetOrbits()
+         b.solveChunk(0, len(b.W))
+         got := make([]bool, len(b.W))
+         for _, k := range b.IndexBounded {
+            got[k] = true
+         }
+         for k, want := range bounded {
+            if got[k] != want {
+               t.Errorf("M1=%g, theta=%.2f: kick of %e cm/s (boundary [%e, %e]) bounded is %v, want %v", cfg.M1, theta, b.W[k], wLower, wUpper, got[k], want)
+            }
+         }
+      }
+   }
+
+}
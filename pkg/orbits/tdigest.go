@@ -0,0 +1,216 @@
+package orbits
+
+import (
+   "encoding/json"
+   "errors"
+   "math"
+   "sort"
+)
+
+
+// default compression of t-digests: about 2*compression centroids are kept, the error of a
+// quantile q is roughly proportional to q(1-q)/compression
+const tdigestCompression = 200.0
+
+
+// centroid of a t-digest: mean of the values it holds and their total weight
+type centroid struct {
+   mean float64
+   weight float64
+}
+
+
+// t-digest (Dunning & Ertl 2019) of weighted values: a sketch of their distribution, of fixed
+// size, from which quantiles are estimated with small errors close to the tails. Values are added
+// to a buffer that is merged into the centroids when full, so a digest is only changed by the
+// values it gets and their order
+type TDigest struct {
+   compression float64
+   centroids []centroid
+   buffer []centroid
+   total float64
+   min float64
+   max float64
+}
+
+
+// new empty t-digest with a given compression
+func NewTDigest (compression float64) *TDigest {
+
+   return &TDigest{compression: compression, min: math.Inf(1), max: math.Inf(-1)}
+
+}
+
+
+// add value x with weight w. Values with non-positive weight (or NaN) are ignored
+func (d *TDigest) Add (x float64, w float64) {
+
+   if w <= 0 || math.IsNaN(x) {
+      return
+   }
+
+   d.buffer = append(d.buffer, centroid{mean: x, weight: w})
+   d.total += w
+   d.min = math.Min(d.min, x)
+   d.max = math.Max(d.max, x)
+
+   if len(d.buffer) >= 5 * int(d.compression) {
+      d.compress()
+   }
+
+}
+
+
+// total weight of the values added
+func (d *TDigest) Weight () float64 {
+
+   return d.total
+
+}
+
+
+// merge the buffer into the centroids. Neighbouring centroids are joined while they span less
+// than one unit of the scale function k(q) = compression/(2pi) asin(2q-1), that keeps centroids
+// small at the tails
+func (d *TDigest) compress () {
+
+   if len(d.buffer) == 0 {
+      return
+   }
+
+   all := append(d.centroids, d.buffer...)
+   sort.SliceStable(all, func (i, j int) bool { return all[i].mean < all[j].mean })
+
+   scale := func (q float64) float64 {
+      return d.compression / (2.0 * math.Pi) * math.Asin(2.0 * q - 1.0)
+   }
+   limit := func (k float64) float64 {
+      return 0.5 * (1.0 + math.Sin(2.0 * math.Pi * math.Min(k, d.compression / 4.0) / d.compression))
+   }
+
+   merged := make([]centroid, 0, int(2 * d.compression))
+   current := all[0]
+   before := 0.0
+   qLimit := limit(scale(0) + 1.0)
+   for _, c := range all[1:] {
+      if (before + current.weight + c.weight) / d.total <= qLimit {
+         // weighted mean, written to keep the mean inside the range of values
+         current.weight += c.weight
+         current.mean += (c.mean - current.mean) * c.weight / current.weight
+         continue
+      }
+      merged = append(merged, current)
+      before += current.weight
+      qLimit = limit(scale(before / d.total) + 1.0)
+      current = c
+   }
+   merged = append(merged, current)
+
+   d.centroids = merged
+   d.buffer = d.buffer[:0]
+
+}
+
+
+// estimate of quantile q (between 0 & 1) of the values, interpolated between the centres of
+// centroids and the extreme values. It is NaN for an empty digest
+func (d *TDigest) Quantile (q float64) float64 {
+
+   d.compress()
+   if len(d.centroids) == 0 {
+      return math.NaN()
+   }
+   if q <= 0 {
+      return d.min
+   }
+   if q >= 1 {
+      return d.max
+   }
+
+   // weight below the quantile, compared with the weight up to the centre of each centroid
+   target := q * d.total
+   before := 0.0
+   prevCentre, prevMean := 0.0, d.min
+   for _, c := range d.centroids {
+      centre := before + 0.5 * c.weight
+      if target < centre {
+         if centre == prevCentre {
+            return c.mean
+         }
+         return prevMean + (c.mean - prevMean) * (target - prevCentre) / (centre - prevCentre)
+      }
+      before += c.weight
+      prevCentre, prevMean = centre, c.mean
+   }
+
+   // between the centre of the last centroid and the maximum
+   if d.total == prevCentre {
+      return d.max
+   }
+
+   return prevMean + (d.max - prevMean) * (target - prevCentre) / (d.total - prevCentre)
+
+}
+
+
+// state of a t-digest in JSON, with every centroid & buffered value, so that a digest read back
+// goes on exactly as the one written
+type tdigestState struct {
+   Compression float64 `json:"compression"`
+   Means []float64 `json:"means"`
+   Weights []float64 `json:"weights"`
+   BufferMeans []float64 `json:"buffer_means"`
+   BufferWeights []float64 `json:"buffer_weights"`
+   Total float64 `json:"total"`
+   Min float64 `json:"min"`
+   Max float64 `json:"max"`
+}
+
+
+// t-digest as JSON. Limits of an empty digest (infinite) are written as zeros
+func (d *TDigest) MarshalJSON () ([]byte, error) {
+
+   s := tdigestState{Compression: d.compression, Total: d.total}
+   if d.total > 0 {
+      s.Min, s.Max = d.min, d.max
+   }
+   for _, c := range d.centroids {
+      s.Means = append(s.Means, c.mean)
+      s.Weights = append(s.Weights, c.weight)
+   }
+   for _, c := range d.buffer {
+      s.BufferMeans = append(s.BufferMeans, c.mean)
+      s.BufferWeights = append(s.BufferWeights, c.weight)
+   }
+
+   return json.Marshal(s)
+
+}
+
+
+// t-digest from JSON, as written by MarshalJSON
+func (d *TDigest) UnmarshalJSON (data []byte) error {
+
+   var s tdigestState
+   err := json.Unmarshal(data, &s)
+   if err != nil {
+      return err
+   }
+   if len(s.Means) != len(s.Weights) || len(s.BufferMeans) != len(s.BufferWeights) {
+      return errors.New("t-digest with different number of means & weights")
+   }
+
+   *d = *NewTDigest(s.Compression)
+   if s.Total > 0 {
+      d.total, d.min, d.max = s.Total, s.Min, s.Max
+   }
+   for k, _ := range s.Means {
+      d.centroids = append(d.centroids, centroid{mean: s.Means[k], weight: s.Weights[k]})
+   }
+   for k, _ := range s.BufferMeans {
+      d.buffer = append(d.buffer, centroid{mean: s.BufferMeans[k], weight: s.BufferWeights[k]})
+   }
+
+   return nil
+
+}
@@ -0,0 +1,84 @@
+package orbits
+
+import (
+   "fmt"
+   "math"
+   "strconv"
+
+   "github.com/asimazbunzel/go-orbits/pkg/io"
+   "github.com/asimazbunzel/go-orbits/pkg/units"
+
+   "gonum.org/v1/gonum/floats"
+)
+
+
+// number of bins of sparklines shown in the terminal
+const sparklineBins = 40
+
+
+// sparklines of the weighted distributions of w, theta & phi of all kicks and of the period of
+// bounded binaries
+func (b *Binary) printSparklines () {
+
+   w := make([]float64, len(b.W))
+   floats.ScaleTo(w, 1.0 / float64(units.KilometerPerSecond), b.W)
+   logP := make([]float64, len(b.PeriodBounded))
+   for k, p := range b.PeriodBounded {
+      logP[k] = math.Log10(units.Time(p).In(units.Day))
+   }
+
+   fmt.Println("Distributions (weighted, min .. max):")
+   printSparkline("w [km/s]", w, b.Weight)
+   printSparkline("theta", b.Theta, b.Weight)
+   printSparkline("phi", b.Phi, b.Weight)
+   printSparkline("log10(P/d) bounded", logP, b.WeightBounded)
+   fmt.Printf("\n")
+
+}
+
+
+// one sparkline of a weighted histogram of x, with its limits
+func printSparkline (name string, x []float64, weight []float64) {
+
+   if len(x) == 0 {
+      return
+   }
+
+   xMin := floats.Min(x)
+   xMax := floats.Max(x)
+   counts := make([]float64, sparklineBins)
+   for k, v := range x {
+      l := sparklineBins - 1
+      if xMax > xMin {
+         l = int(float64(sparklineBins) * (v - xMin) / (xMax - xMin))
+      }
+      if l >= sparklineBins {
+         l = sparklineBins - 1
+      }
+      counts[l] += weight[k]
+   }
+
+   fmt.Printf("%20s  %9.2E %s %9.2E\n", name, xMin, io.Sparkline(counts), xMax)
+
+}
+
+
+// heatmap of every cell of a regular grid, eccentricity in rows and period in columns
+func (b *Binary) printGridHeatmap () {
+
+   if b.ProbabilityMatrix == nil {
+      return
+   }
+
+   rowLabels := make([]string, len(b.EccentricityBordersGrid)-1)
+   for i, _ := range rowLabels {
+      rowLabels[i] = strconv.FormatFloat(0.5 * (b.EccentricityBordersGrid[i] + b.EccentricityBordersGrid[i+1]), 'f', 2, 64)
+   }
+   pMin := units.Time(b.PeriodBordersGrid[0]).In(units.Day)
+   pMax := units.Time(b.PeriodBordersGrid[len(b.PeriodBordersGrid)-1]).In(units.Day)
+   colLabels := []string{strconv.FormatFloat(pMin, 'E', 1, 64), strconv.FormatFloat(pMax, 'E', 1, 64)}
+
+   fmt.Println("\nProbability of each cell (eccentricity vs period [days]):")
+   fmt.Print(io.Heatmap(b.ProbabilityMatrix, rowLabels, colLabels))
+
+}
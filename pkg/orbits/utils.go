@@ -3,84 +3,157 @@ package orbits
 
 import (
 	"math"
+	"sort"
 	
    "github.com/asimazbunzel/go-orbits/pkg/io"
+   "github.com/asimazbunzel/go-orbits/pkg/units"
+
+   "gonum.org/v1/gonum/floats"
 )
 
 
-// kepler law to get binary separation from orbital period
-func PtoA (p float64, m1 float64, m2 float64) float64 {
+// kepler law to get binary separation from orbital period, for masses m1 & m2 orbiting each other
+func PtoA (p units.Time, m1 units.Mass, m2 units.Mass) units.Length {
+
+   return units.Length(math.Pow(units.G * float64(m1 + m2) * math.Pow(float64(p)/(2.0*math.Pi),2.0), 1.0/3.0))
+
+}
+
+
+// kepler law to get orbital period from binary separation, for masses m1 & m2 orbiting each other
+func AtoP (a units.Length, m1 units.Mass, m2 units.Mass) units.Time {
+
+   return units.Time((2.0*math.Pi) * math.Pow(math.Pow(float64(a),3.0) / (units.G * float64(m1 + m2)),0.5))
+
+}
+
+
+// relative velocity of masses m1 & m2 in a circular orbit of separation a
+func OrbitalVelocity (a units.Length, m1 units.Mass, m2 units.Mass) units.Velocity {
+
+   return units.Velocity(math.Sqrt(units.G * float64(m1 + m2) / float64(a)))
+
+}
+
+
+// time to merge due to emission of gravitational waves (Peters 1964), using the fit to the
+// dependence on eccentricity of Mandel 2021 (RNAAS 5, 223)
+func MergerTime (a units.Length, e float64, m1 units.Mass, m2 units.Mass) units.Time {
+
+   tCircular := 5.0 * math.Pow(units.C,5.0) * math.Pow(float64(a),4.0) / (256.0 * math.Pow(units.G,3.0) * float64(m1) * float64(m2) * float64(m1 + m2))
+
+   return units.Time(tCircular * math.Pow(1.0 - math.Pow(e,2.0), 3.5) * (1.0 + 0.27 * math.Pow(e,10.0) + 0.33 * math.Pow(e,20.0) + 0.2 * math.Pow(e,1000.0)))
 
-   return math.Pow(StandardCgrav * (m1 + m2) * math.Pow(p/(2.0*math.Pi),2.0), 1.0/3.0)
+}
+
+
+// mass of the exploding star before the SN
+func (b *Binary) mass1 () units.Mass {
+
+   return units.Mass(b.M1) * units.SolarMass
+
+}
+
+
+// mass of the companion
+func (b *Binary) mass2 () units.Mass {
+
+   return units.Mass(b.M2) * units.SolarMass
 
 }
 
 
-// kepler law to get orbital period from binary separation
-func AtoP (a float64, m1 float64, m2 float64) float64 {
+// mass of the compact object left by the SN
+func (b *Binary) massCO () units.Mass {
 
-   return (2.0*math.Pi) * math.Pow(math.Pow(a,3.0) / (StandardCgrav * (m1 + m2)),0.5)
+   return units.Mass(b.MCO) * units.SolarMass
 
 }
 
 
-// input should be in Msun / Rsun / Lsun and so on.. here we change it to CGS
+// separation before the SN
+func (b *Binary) separation () units.Length {
+
+   return units.Length(b.Separation) * units.SolarRadius
+
+}
+
+
+// kicks & results are converted to CGS (cm/s, cm, s), the units in which orbits & grids are
+// computed. Options are always kept as they are in the configuration (Msun, Rsun, days, km/s)
+// and converted when needed. Nothing is done if already in CGS
 func (b *Binary) ConvertoCGS () {
 
+   if b.cgs {
+      return
+   }
+   b.cgs = true
+
    if b.LogLevel != "none"{
       io.LogInfo("ORBITS - orbits.go - ConvertCGS", "converting to CGS units")
    }
 
-   b.M1 = b.M1 * Msun
-   b.M2 = b.M2 * Msun
-   b.Separation = b.Separation * Rsun
-   b.Period = b.Period * 24.0 * 3600.0
-   b.MCO = b.MCO * Msun
-
-   for k, w := range b.W {
-      b.W[k] = w * km2cm
-   }
+   floats.Scale(float64(units.KilometerPerSecond), b.W)
 
 }
 
 
-// let's go back from CGS to astro units
+// let's go back from CGS to astro units: kicks in km/s and results in Rsun, days & yr.
+// nothing is done if already in astro units
 func (b *Binary) ConvertoAstro () {
 
+   if !b.cgs {
+      return
+   }
+   b.cgs = false
+
    if b.LogLevel != "none"{
       io.LogInfo("ORBITS - orbits.go - ConvertoAstro", "converting to Astro units (Msun, Rsun, etc)")
    }
 
-   b.M1 = b.M1 / Msun
+   velocity := 1.0 / float64(units.KilometerPerSecond)
+   length := 1.0 / float64(units.SolarRadius)
+   time := 1.0 / float64(units.Day)
 
-   b.M2 = b.M2 / Msun
-   b.Separation = b.Separation / Rsun
-   b.Period = b.Period / 24.0 / 3600.0
-   b.MCO = b.MCO / Msun
+   floats.Scale(velocity, b.W)
 
-   for k, w := range b.W {
-      b.W[k] = w / km2cm
-   }
+   floats.Scale(velocity, b.WBounded)
+   floats.Scale(length, b.SeparationBounded)
+   floats.Scale(time, b.PeriodBounded)
+   floats.Scale(velocity, b.VsysBounded)
+   floats.Scale(1.0 / float64(units.Year), b.TimeGWBounded)
 
-   for k,w := range b.WBounded {
-      b.WBounded[k] = w / km2cm
-      b.SeparationBounded[k] = b.SeparationBounded[k] / Rsun
-      b.PeriodBounded[k] = b.PeriodBounded[k] / 24.0 / 3600.0
+   floats.Scale(time, b.PeriodGrid)
+   floats.Scale(length, b.SeparationGrid)
+   floats.Scale(time, b.PeriodLowerGrid)
+   floats.Scale(time, b.PeriodUpperGrid)
+   floats.Scale(time, b.PeriodBordersGrid)
+
+   for d, edges := range b.MultiGridEdges {
+      floats.Scale(1.0 / astroFactor(b.GridAxes[d].Quantity), edges)
    }
 
-   for k, _ := range b.PeriodGrid {
-      b.PeriodGrid[k] = b.PeriodGrid[k] / 24.0 / 3600.0
-      b.SeparationGrid[k] = b.SeparationGrid[k] / Rsun
+   floats.Scale(time, b.PeriodRepresentative)
+   floats.Scale(length, b.SeparationRepresentative)
+   floats.Scale(velocity, b.VsysRepresentative)
+
+   floats.Scale(velocity, b.KickThetaMap.XEdges)
+   for _, m := range []SurvivalMap{b.KickThetaMap, b.ThetaPhiMap} {
+      for i, _ := range m.MeanSeparation {
+         floats.Scale(length, m.MeanSeparation[i])
+      }
    }
+   floats.Scale(velocity, b.MinKickBoundary)
+   floats.Scale(velocity, b.MaxKickBoundary)
 
 }
 
 
 // linspace function
-func LinSpace (xi float64, xf float64, num int) []float64 {
+func LinSpace (xi float64, xf float64, num int) ([]float64, error) {
 
    if num <= 1 {
-      io.LogError("ORBITS - orbits.go - LinSpace", "`num` must be greater than 1")
+      return nil, &GridError{Message: "`num` must be greater than 1 in LinSpace"}
    }
 
    xstep := (xf - xi) / float64(num-1)
@@ -91,16 +164,19 @@ func LinSpace (xi float64, xf float64, num int) []float64 {
 	}
    x[num-1] = xf
 	
-   return x
+   return x, nil
 
 }
 
 
 // logspace function
-func LogSpace (xi float64, xf float64, num int, base float64) []float64 {
+func LogSpace (xi float64, xf float64, num int, base float64) ([]float64, error) {
 
    // first, get power in linspace
-   xpower := LinSpace(xi, xf, num)
+   xpower, err := LinSpace(xi, xf, num)
+   if err != nil {
+      return nil, err
+   }
 
    // now loop over array and compute its power
    x := make([]float64, num)
@@ -108,7 +184,7 @@ func LogSpace (xi float64, xf float64, num int, base float64) []float64 {
       x[k] = math.Pow(base, xpower[k])
    }
 
-   return x
+   return x, nil
 }
 
 
@@ -121,3 +197,70 @@ func CountDigits (number int) int {
       return 1 + CountDigits(number / 10)
    }
 }
+
+
+// return sorted copies of a slice and of the weights associated to each of its elements
+func SortWithWeights (x []float64, weights []float64) ([]float64, []float64) {
+
+   index := make([]int, len(x))
+   for k, _ := range index {
+      index[k] = k
+   }
+   sort.SliceStable(index, func(i, j int) bool { return x[index[i]] < x[index[j]] })
+
+   xSorted := make([]float64, len(x))
+   wSorted := make([]float64, len(x))
+   for k, i := range index {
+      xSorted[k] = x[i]
+      wSorted[k] = weights[i]
+   }
+
+   return xSorted, wSorted
+}
+
+
+// effective number of samples (Kish) of a weighted sample
+func EffectiveSampleSize (weights []float64) float64 {
+
+   sum := 0.0
+   sum2 := 0.0
+   for _, w := range weights {
+      sum += w
+      sum2 += w * w
+   }
+
+   return effectiveSize(sum, sum2)
+}
+
+
+// effective sample size from the sum of weights and the sum of their squares
+func effectiveSize (sum float64, sum2 float64) float64 {
+
+   if sum2 == 0 {
+      return 0
+   }
+
+   return sum * sum / sum2
+
+}
+
+
+// empirical quantile of a sorted slice with weights. Unlike stat.Quantile, it does not fail when
+// rounding errors in the cumulative sum of weights fall short of the total
+func WeightedQuantile (p float64, x []float64, weights []float64) float64 {
+
+   total := 0.0
+   for _, w := range weights {
+      total += w
+   }
+
+   cumsum := 0.0
+   for k, w := range weights {
+      cumsum += w
+      if cumsum >= p * total {
+         return x[k]
+      }
+   }
+
+   return x[len(x)-1]
+}
@@ -0,0 +1,92 @@
+package orbits
+
+import (
+   "math"
+   "testing"
+
+   "github.com/asimazbunzel/go-orbits/pkg/units"
+)
+
+
+// relative difference between x & y
+func relativeDifference (x float64, y float64) float64 {
+
+   return math.Abs(x - y) / math.Max(math.Abs(x), math.Abs(y))
+
+}
+
+
+// the orbit of the Earth: 1 AU around 1 Msun (plus 1 Mearth) takes a sidereal year
+func TestAtoPKnownValue (t *testing.T) {
+
+   au := units.Length(1.495978707e13)
+   mEarth := units.Mass(3.003e-6) * units.SolarMass
+
+   p := AtoP(au, units.SolarMass, mEarth)
+   if d := relativeDifference(p.In(units.Day), 365.256); d > 1e-4 {
+      t.Errorf("period of the Earth: got %f days, want 365.256 days", p.In(units.Day))
+   }
+
+   a := PtoA(units.Time(365.256) * units.Day, units.SolarMass, mEarth)
+   if d := relativeDifference(float64(a), float64(au)); d > 1e-4 {
+      t.Errorf("separation of the Earth: got %e cm, want %e cm", float64(a), float64(au))
+   }
+
+}
+
+
+// PtoA & AtoP are each other's inverse
+func TestAtoPInverse (t *testing.T) {
+
+   masses := [][2]float64{{1, 1}, {10, 8}, {1.4, 30}, {50, 0.1}}
+   for _, m := range masses {
+      m1, m2 := units.Mass(m[0]) * units.SolarMass, units.Mass(m[1]) * units.SolarMass
+      for _, days := range []float64{0.01, 1, 100, 1e5} {
+         p := units.Time(days) * units.Day
+         got := AtoP(PtoA(p, m1, m2), m1, m2)
+         if d := relativeDifference(float64(got), float64(p)); d > 1e-12 {
+            t.Errorf("AtoP(PtoA(%g d)) with masses %v: got %g d", days, m, got.In(units.Day))
+         }
+         a := units.Length(days) * units.SolarRadius
+         back := PtoA(AtoP(a, m1, m2), m1, m2)
+         if d := relativeDifference(float64(back), float64(a)); d > 1e-12 {
+            t.Errorf("PtoA(AtoP(%g Rsun)) with masses %v: got %g Rsun", days, m, back.In(units.SolarRadius))
+         }
+      }
+   }
+
+}
+
+
+// without a kick, the orbit only changes by the mass lost in the SN: a' = a Mpost / (2 Mpost - Mpre)
+// and e' = (Mpre - Mpost) / Mpost, with its period from Kepler's law with the masses after the SN
+func TestSolveChunkWithoutKick (t *testing.T) {
+
+   b := NewBinary(Config{M1: 10, M2: 8, MCO: 7, Separation: 50, LogLevel: "none"})
+   b.W, b.Theta, b.Phi, b.Weight = []float64{0}, []float64{0}, []float64{0}, []float64{1}
+   b.solveChunk(0, 1)
+
+   if len(b.IndexBounded) != 1 {
+      t.Fatalf("binary must remain bounded without a kick, got %d bounded binaries", len(b.IndexBounded))
+   }
+
+   mPre, mPost := b.M1 + b.M2, b.MCO + b.M2
+   a := float64(b.separation()) * mPost / (2.0 * mPost - mPre)
+   if d := relativeDifference(b.SeparationBounded[0], a); d > 1e-12 {
+      t.Errorf("separation after the SN: got %e cm, want %e cm", b.SeparationBounded[0], a)
+   }
+   e := (mPre - mPost) / mPost
+   if math.Abs(b.EccentricityBounded[0] - e) > 1e-12 {
+      t.Errorf("eccentricity after the SN: got %f, want %f", b.EccentricityBounded[0], e)
+   }
+
+   p := float64(AtoP(units.Length(a), b.massCO(), b.mass2()))
+   if d := relativeDifference(b.PeriodBounded[0], p); d > 1e-12 {
+      t.Errorf("period after the SN: got %e s, want %e s (Kepler with MCO + M2)", b.PeriodBounded[0], p)
+   }
+   pPre := float64(AtoP(units.Length(a), b.mass1(), b.mass2()))
+   if d := relativeDifference(b.PeriodBounded[0], pPre); d < 1e-3 {
+      t.Errorf("period after the SN: got %e s, which is Kepler with M1 + M2", b.PeriodBounded[0])
+   }
+
+}
@@ -0,0 +1,446 @@
+package orbits
+
+import (
+   "archive/zip"
+   "encoding/binary"
+   "encoding/csv"
+   "errors"
+   "fmt"
+   "io"
+   "math"
+   "strconv"
+   "strings"
+
+   "github.com/xitongsys/parquet-go/parquet"
+   "github.com/xitongsys/parquet-go/writer"
+)
+
+
+// formats of output files
+var outputFormats = []string{"text", "csv", "jsonl", "parquet", "npy", "npz", "fits"}
+
+
+// column of a table of outputs. Integer columns (e.g. ids) are written without decimals
+type Column struct {
+   Name string
+   Values []float64
+   Integer bool
+   Unit string
+}
+
+
+// keyword of a table, such as a configuration option
+type Keyword struct {
+   Name string
+   Value string
+}
+
+
+// table of outputs: columns of equal length, plus its name, keywords and comments (such as the
+// borders of a grid) for formats that can hold them
+type Table struct {
+   Name string
+   Columns []Column
+   Keywords []Keyword
+   Comments []string
+}
+
+
+// number of rows of a table
+func (t Table) Rows () int {
+
+   if len(t.Columns) == 0 {
+      return 0
+   }
+
+   return len(t.Columns[0].Values)
+
+}
+
+
+// writer of tables to files of a given format
+type TableWriter interface {
+   Write (w io.Writer, t Table) error
+}
+
+
+// writer of tables whose rows can be written in parts, after a header written once (e.g. for
+// outputs of streaming runs)
+type RowWriter interface {
+   TableWriter
+   WriteHeader (w io.Writer, t Table) error
+   WriteRows (w io.Writer, t Table) error
+}
+
+
+// writer for a format: text (fixed-width columns with 5 significant digits, as always), csv,
+// jsonl (JSON Lines), parquet, npy (a NumPy structured array) or npz (a NumPy archive with one
+// array per column) or fits (a FITS binary table). Except for text, numbers keep full float64
+// precision
+func NewTableWriter (format string) (TableWriter, error) {
+
+   switch format {
+   case "", "text":
+      return textWriter{}, nil
+   case "csv":
+      return csvWriter{}, nil
+   case "jsonl":
+      return jsonlWriter{}, nil
+   case "parquet":
+      return parquetWriter{}, nil
+   case "npy":
+      return npyWriter{}, nil
+   case "npz":
+      return npzWriter{}, nil
+   case "fits":
+      return fitsWriter{}, nil
+   }
+
+   return nil, errors.New("unknown format: " + format + ", options are: " + strings.Join(outputFormats, ", "))
+
+}
+
+
+// number as text, in full precision unless short. Values of columns that are not integers always
+// have a decimal point or an exponent (e.g. 1.0, not 1), so that they are read back as such
+func formatValue (x float64, integer bool, short bool) string {
+
+   if integer {
+      return strconv.FormatInt(int64(x), 10)
+   }
+   if short {
+      return strconv.FormatFloat(x, 'E', 5, 64)
+   }
+
+   str := strconv.FormatFloat(x, 'g', -1, 64)
+   if !strings.ContainsAny(str, ".eEIN") {
+      str += ".0"
+   }
+
+   return str
+
+}
+
+
+// whether a format holds keywords & units of tables, otherwise they go to a sidecar file
+func embedsMetadata (format string) bool {
+
+   switch format {
+   case "", "text", "csv", "parquet", "fits":
+      return true
+   }
+
+   return false
+
+}
+
+
+// fixed-width text, with keywords, units & comments in lines starting with #
+type textWriter struct{}
+
+func (tw textWriter) Write (w io.Writer, t Table) error {
+
+   err := tw.WriteHeader(w, t)
+   if err != nil {
+      return err
+   }
+
+   return tw.WriteRows(w, t)
+
+}
+
+func (textWriter) WriteHeader (w io.Writer, t Table) error {
+
+   str := ""
+   for _, line := range metadataLines(t) {
+      str += "# " + line + "\n"
+   }
+   for _, comment := range t.Comments {
+      str += "# " + comment + "\n"
+   }
+   for _, c := range t.Columns {
+      str += fmt.Sprintf("%20s", c.Name)
+   }
+   _, err := io.WriteString(w, str + "\n")
+
+   return err
+
+}
+
+func (textWriter) WriteRows (w io.Writer, t Table) error {
+
+   for k := 0; k < t.Rows(); k++ {
+      str := ""
+      for _, c := range t.Columns {
+         str += fmt.Sprintf("%20s", formatValue(c.Values[k], c.Integer, true))
+      }
+      _, err := io.WriteString(w, str + "\n")
+      if err != nil {
+         return err
+      }
+   }
+
+   return nil
+
+}
+
+
+// comma-separated values, with keywords, units & comments in lines starting with #
+type csvWriter struct{}
+
+func (cw csvWriter) Write (w io.Writer, t Table) error {
+
+   err := cw.WriteHeader(w, t)
+   if err != nil {
+      return err
+   }
+
+   return cw.WriteRows(w, t)
+
+}
+
+func (csvWriter) WriteHeader (w io.Writer, t Table) error {
+
+   for _, comment := range append(metadataLines(t), t.Comments...) {
+      _, err := io.WriteString(w, "# " + comment + "\n")
+      if err != nil {
+         return err
+      }
+   }
+
+   cw := csv.NewWriter(w)
+   record := make([]string, len(t.Columns))
+   for j, c := range t.Columns {
+      record[j] = c.Name
+   }
+   cw.Write(record)
+   cw.Flush()
+
+   return cw.Error()
+
+}
+
+func (csvWriter) WriteRows (w io.Writer, t Table) error {
+
+   cw := csv.NewWriter(w)
+   record := make([]string, len(t.Columns))
+   for k := 0; k < t.Rows(); k++ {
+      for j, c := range t.Columns {
+         record[j] = formatValue(c.Values[k], c.Integer, false)
+      }
+      cw.Write(record)
+   }
+   cw.Flush()
+
+   return cw.Error()
+
+}
+
+
+// one JSON object per row. JSON has no NaN or infinity, they are written as null
+type jsonlWriter struct{}
+
+func (jw jsonlWriter) Write (w io.Writer, t Table) error {
+
+   return jw.WriteRows(w, t)
+
+}
+
+// rows hold their own column names, there is no header
+func (jsonlWriter) WriteHeader (w io.Writer, t Table) error {
+
+   return nil
+
+}
+
+func (jsonlWriter) WriteRows (w io.Writer, t Table) error {
+
+   for k := 0; k < t.Rows(); k++ {
+      fields := make([]string, len(t.Columns))
+      for j, c := range t.Columns {
+         value := "null"
+         x := c.Values[k]
+         if !math.IsNaN(x) && !math.IsInf(x, 0) {
+            value = formatValue(x, c.Integer, false)
+         }
+         fields[j] = strconv.Quote(c.Name) + ":" + value
+      }
+      _, err := io.WriteString(w, "{" + strings.Join(fields, ",") + "}\n")
+      if err != nil {
+         return err
+      }
+   }
+
+   return nil
+
+}
+
+
+// Apache Parquet, with keywords, units (as "unit.<column>") & comments as key-value metadata of
+// the file
+type parquetWriter struct{}
+
+func (parquetWriter) Write (w io.Writer, t Table) error {
+
+   schema := make([]string, len(t.Columns))
+   for j, c := range t.Columns {
+      kind := "DOUBLE"
+      if c.Integer {
+         kind = "INT64"
+      }
+      schema[j] = "name=" + c.Name + ", type=" + kind
+   }
+
+   pw, err := writer.NewCSVWriterFromWriter(schema, w, 1)
+   if err != nil {
+      return err
+   }
+
+   metadata := func (key string, value string) {
+      pw.Footer.KeyValueMetadata = append(pw.Footer.KeyValueMetadata, &parquet.KeyValue{Key: key, Value: &value})
+   }
+   for _, kw := range t.Keywords {
+      metadata(kw.Name, kw.Value)
+   }
+   for _, c := range t.Columns {
+      if c.Unit != "" {
+         metadata("unit." + c.Name, c.Unit)
+      }
+   }
+   if len(t.Comments) > 0 {
+      metadata("comments", strings.Join(t.Comments, "\n"))
+   }
+
+   for k := 0; k < t.Rows(); k++ {
+      record := make([]interface{}, len(t.Columns))
+      for j, c := range t.Columns {
+         if c.Integer {
+            record[j] = int64(c.Values[k])
+         } else {
+            record[j] = c.Values[k]
+         }
+      }
+      err = pw.Write(record)
+      if err != nil {
+         return err
+      }
+   }
+
+   return pw.WriteStop()
+
+}
+
+
+// NumPy structured array, with one field per column
+type npyWriter struct{}
+
+func (npyWriter) Write (w io.Writer, t Table) error {
+
+   fields := make([]string, len(t.Columns))
+   for j, c := range t.Columns {
+      fields[j] = "('" + c.Name + "', '" + npyType(c) + "')"
+   }
+   descr := "[" + strings.Join(fields, ", ") + "]"
+
+   err := writeNpyHeader(w, descr, t.Rows())
+   if err != nil {
+      return err
+   }
+
+   // rows are stored one after the other
+   row := make([]byte, 8 * len(t.Columns))
+   for k := 0; k < t.Rows(); k++ {
+      for j, c := range t.Columns {
+         putNpyValue(row[8*j:], c, k)
+      }
+      _, err := w.Write(row)
+      if err != nil {
+         return err
+      }
+   }
+
+   return nil
+
+}
+
+
+// NumPy archive (as numpy.savez), with one array per column
+type npzWriter struct{}
+
+func (npzWriter) Write (w io.Writer, t Table) error {
+
+   zw := zip.NewWriter(w)
+   for _, c := range t.Columns {
+      f, err := zw.Create(c.Name + ".npy")
+      if err != nil {
+         return err
+      }
+      err = writeNpyHeader(f, "'" + npyType(c) + "'", len(c.Values))
+      if err != nil {
+         return err
+      }
+      data := make([]byte, 8 * len(c.Values))
+      for k, _ := range c.Values {
+         putNpyValue(data[8*k:], c, k)
+      }
+      _, err = f.Write(data)
+      if err != nil {
+         return err
+      }
+   }
+
+   return zw.Close()
+
+}
+
+
+// NumPy type of a column: little-endian 64-bit integers or floats
+func npyType (c Column) string {
+
+   if c.Integer {
+      return "<i8"
+   }
+
+   return "<f8"
+
+}
+
+
+// value k of a column in the first 8 bytes of b, in little-endian order
+func putNpyValue (b []byte, c Column, k int) {
+
+   if c.Integer {
+      binary.LittleEndian.PutUint64(b, uint64(int64(c.Values[k])))
+   } else {
+      binary.LittleEndian.PutUint64(b, math.Float64bits(c.Values[k]))
+   }
+
+}
+
+
+// header of a one-dimensional .npy file (format version 1.0) with n elements of type descr. The
+// header is padded with spaces so that data starts at a multiple of 64 bytes
+func writeNpyHeader (w io.Writer, descr string, n int) error {
+
+   header := "{'descr': " + descr + ", 'fortran_order': False, 'shape': (" + strconv.Itoa(n) + ",), }"
+   // magic string (6), version (2) and header length (2) come before the header
+   padding := 64 - (10 + len(header) + 1) % 64
+   if padding == 64 {
+      padding = 0
+   }
+   header += strings.Repeat(" ", padding) + "\n"
+   if len(header) > math.MaxUint16 {
+      return errors.New("too many columns for a .npy header")
+   }
+
+   preamble := []byte{0x93, 'N', 'U', 'M', 'P', 'Y', 1, 0, 0, 0}
+   binary.LittleEndian.PutUint16(preamble[8:], uint16(len(header)))
+   _, err := w.Write(preamble)
+   if err != nil {
+      return err
+   }
+   _, err = io.WriteString(w, header)
+
+   return err
+
+}
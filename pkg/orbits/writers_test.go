@@ -0,0 +1,167 @@
+package orbits
+
+import (
+   "bytes"
+   "context"
+   "path/filepath"
+   "testing"
+)
+
+
+// columns keep their kind and values when written & read back in every format, including
+// columns of floats whose values are all integral (e.g. unit weights)
+func TestWriteReadTable (t *testing.T) {
+
+   table := Table{Columns: []Column{
+      {Name: "id", Values: []float64{0, 1, 2}, Integer: true},
+      {Name: "w", Values: []float64{1.5, 265, 1e-3}},
+      {Name: "weight", Values: []float64{1, 1, 1}},
+   }}
+
+   for _, format := range outputFormats {
+      tw, err := NewTableWriter(format)
+      if err != nil {
+         t.Fatal(err)
+      }
+      var buf bytes.Buffer
+      err = tw.Write(&buf, table)
+      if err != nil {
+         t.Fatalf("%s: %v", format, err)
+      }
+
+      tr, err := NewTableReader(format)
+      if err != nil {
+         t.Fatal(err)
+      }
+      got, err := tr.Read(buf.Bytes())
+      if err != nil {
+         t.Fatalf("%s: %v", format, err)
+      }
+
+      for _, want := range table.Columns {
+         c, ok := got.Column(want.Name)
+         if !ok {
+            t.Errorf("%s: column %s not found", format, want.Name)
+            continue
+         }
+         if c.Integer != want.Integer {
+            t.Errorf("%s: column %s read with Integer=%v, want %v", format, want.Name, c.Integer, want.Integer)
+         }
+         // text is written with 6 significant digits
+         for k, x := range want.Values {
+            if d := c.Values[k] - x; d > 1e-5 * x || d < -1e-5 * x {
+               t.Errorf("%s: value %d of column %s is %g, want %g", format, k, want.Name, c.Values[k], x)
+            }
+         }
+      }
+   }
+
+}
+
+
+// FITS headers only have standard cards: keywords too long for a HIERARCH card become comments
+func TestFitsLongKeywords (t *testing.T) {
+
+   long := `[{"quantity":"period","scale":"log","bins":20,"quantile_min":0.01,"quantile_max":0.99}]`
+   table := Table{
+      Columns: []Column{{Name: "w", Values: []float64{1.5}}},
+      Keywords: []Keyword{{Name: "grid_axes", Value: long}, {Name: "seed", Value: "1000"}},
+   }
+
+   var buf bytes.Buffer
+   err := fitsWriter{}.Write(&buf, table)
+   if err != nil {
+      t.Fatal(err)
+   }
+   data := buf.Bytes()
+   for k := 0; k + fitsCard <= len(data); k += fitsCard {
+      if bytes.HasPrefix(data[k:], []byte("CONTINUE")) {
+         t.Fatalf("card %d is a CONTINUE card", k / fitsCard)
+      }
+   }
+
+   got, err := fitsReader{}.Read(data)
+   if err != nil {
+      t.Fatal(err)
+   }
+   if seed, _ := got.Keyword("seed"); seed != "1000" {
+      t.Errorf("seed: got %q, want 1000", seed)
+   }
+   if _, ok := got.Keyword("grid_axes"); ok {
+      t.Error("grid_axes written as a HIERARCH card, too long for one")
+   }
+   comments := ""
+   for _, c := range got.Comments {
+      comments += c
+   }
+   if comments != "grid_axes: " + long {
+      t.Errorf("comments: got %q, want grid_axes: %s", comments, long)
+   }
+
+}
+
+
+// values that are not decimal numbers, e.g. an infinite achieved_error, are quoted strings
+func TestFitsNonDecimalKeywords (t *testing.T) {
+
+   table := Table{
+      Columns: []Column{{Name: "w", Values: []float64{1.5}}},
+      Keywords: []Keyword{{Name: "achieved_error", Value: "+Inf"}, {Name: "bounded_nan", Value: "NaN"}, {Name: "hex_value", Value: "0x1p-2"}, {Name: "bounded_fraction", Value: "1.5E-01"}},
+   }
+
+   var buf bytes.Buffer
+   err := fitsWriter{}.Write(&buf, table)
+   if err != nil {
+      t.Fatal(err)
+   }
+   data := buf.Bytes()
+   for _, card := range []string{"HIERARCH ACHIEVED_ERROR = '+Inf'", "HIERARCH BOUNDED_NAN = 'NaN'", "HIERARCH HEX_VALUE = '0x1p-2'", "HIERARCH BOUNDED_FRACTION = 1.5E-01 "} {
+      if !bytes.Contains(data, []byte(card)) {
+         t.Errorf("no card %q in the header", card)
+      }
+   }
+
+   got, err := fitsReader{}.Read(data)
+   if err != nil {
+      t.Fatal(err)
+   }
+   if v, _ := got.Keyword("achieved_error"); v != "+Inf" {
+      t.Errorf("achieved_error: got %q, want +Inf", v)
+   }
+
+}
+
+
+// survival maps, boundaries, multi-dimensional grids and marginals are written in their format,
+// and unknown formats are configuration errors before the run
+func TestSaveOutputFormats (t *testing.T) {
+
+   cfg := testConfig(2000)
+   cfg.StoreSurvivalMaps = true
+   cfg.SurvivalMapKickBins, cfg.SurvivalMapThetaBins, cfg.SurvivalMapPhiBins = 5, 4, 4
+   cfg.SurvivalBoundaryFormat = "xml"
+   b := NewBinary(cfg)
+   err := b.Run(context.Background())
+   if e, ok := err.(*ConfigError); !ok || e.Option != "survival_boundary_format" {
+      t.Fatalf("unknown format: got error %v, want a ConfigError on survival_boundary_format", err)
+   }
+
+   b.SurvivalBoundaryFormat = "csv"
+   err = b.Run(context.Background())
+   if err != nil {
+      t.Fatal(err)
+   }
+   filename := filepath.Join(t.TempDir(), "survival_boundary.csv")
+   err = b.SaveSurvivalBoundary(filename)
+   if err != nil {
+      t.Fatal(err)
+   }
+   table, err := ReadTable(filename, "csv")
+   if err != nil {
+      t.Fatal(err)
+   }
+   if table.Rows() != len(b.ThetaBoundary) {
+      t.Errorf("survival boundary in csv: got %d rows, want %d", table.Rows(), len(b.ThetaBoundary))
+   }
+
+}
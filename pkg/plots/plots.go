@@ -0,0 +1,242 @@
+// Package plots makes figures of kicks, orbits and grids of orbits, so that no python install is
+// needed to look at the outputs
+package plots
+
+import (
+   "errors"
+   "fmt"
+   "math"
+   "os"
+
+   "gonum.org/v1/plot"
+   "gonum.org/v1/plot/palette"
+   "gonum.org/v1/plot/plotter"
+   "gonum.org/v1/plot/vg"
+   "gonum.org/v1/plot/vg/draw"
+)
+
+
+// size of single figures & of each panel of a corner plot
+var (
+   Width = 12 * vg.Centimeter
+   Height = 9 * vg.Centimeter
+   PanelSize = 5 * vg.Centimeter
+)
+
+
+// histogram of x with weights (all equal if nil, e.g. for tables without them), normalized to
+// unit area
+func Histogram (x []float64, weight []float64, bins int, xLabel string) (*plot.Plot, error) {
+
+   p := plot.New()
+   p.X.Label.Text = xLabel
+   p.Y.Label.Text = "density"
+
+   if weight == nil {
+      weight = make([]float64, len(x))
+      for k, _ := range weight {
+         weight[k] = 1.0
+      }
+   }
+   xy, err := pairs(x, weight)
+   if err != nil {
+      return nil, err
+   }
+   h, err := plotter.NewHistogram(xy, bins)
+   if err != nil {
+      return nil, err
+   }
+   h.Normalize(1)
+   p.Add(h)
+
+   return p, nil
+
+}
+
+
+// scatter plot of (x, y), with a logarithmic x-axis if logX
+func Scatter (x []float64, y []float64, xLabel string, yLabel string, logX bool) (*plot.Plot, error) {
+
+   p := plot.New()
+   p.X.Label.Text = xLabel
+   p.Y.Label.Text = yLabel
+   if logX {
+      p.X.Scale = plot.LogScale{}
+      p.X.Tick.Marker = plot.LogTicks{Prec: -1}
+   }
+
+   xy, err := pairs(x, y)
+   if err != nil {
+      return nil, err
+   }
+   s, err := plotter.NewScatter(xy)
+   if err != nil {
+      return nil, err
+   }
+   s.GlyphStyle.Radius = vg.Points(1)
+   p.Add(s)
+
+   return p, nil
+
+}
+
+
+// values of a matrix at the centre of the cells set by its edges, as needed by heatmaps. Rows of
+// z are y-values and columns x-values
+type matrixGrid struct {
+   x, y []float64
+   z [][]float64
+}
+
+func (g matrixGrid) Dims () (int, int) { return len(g.x), len(g.y) }
+func (g matrixGrid) Z (c int, r int) float64 { return g.z[r][c] }
+func (g matrixGrid) X (c int) float64 { return g.x[c] }
+func (g matrixGrid) Y (r int) float64 { return g.y[r] }
+
+
+// heatmap of a matrix with rows in y and columns in x, whose cells have borders xEdges & yEdges
+func Heatmap (xEdges []float64, yEdges []float64, z [][]float64, xLabel string, yLabel string) (*plot.Plot, error) {
+
+   if len(z) != len(yEdges) - 1 || len(z) == 0 || len(z[0]) != len(xEdges) - 1 {
+      return nil, errors.New("matrix does not match the number of edges")
+   }
+
+   g := matrixGrid{x: centers(xEdges), y: centers(yEdges), z: z}
+
+   p := plot.New()
+   p.X.Label.Text = xLabel
+   p.Y.Label.Text = yLabel
+   p.Add(plotter.NewHeatMap(g, palette.Heat(12, 1)))
+
+   return p, nil
+
+}
+
+
+// corner plot of the columns: histograms in the diagonal and scatter plots of each pair below
+// it. Scatter plots use at most maxPoints points
+func Corner (columns [][]float64, weight []float64, names []string, bins int, maxPoints int) ([][]*plot.Plot, error) {
+
+   n := len(columns)
+   plots := make([][]*plot.Plot, n)
+   for i := 0; i < n; i++ {
+      plots[i] = make([]*plot.Plot, n)
+      for j := 0; j <= i; j++ {
+         var err error
+         if i == j {
+            plots[i][j], err = Histogram(columns[i], weight, bins, "")
+         } else {
+            plots[i][j], err = Scatter(thin(columns[j], maxPoints), thin(columns[i], maxPoints), "", "", false)
+         }
+         if err != nil {
+            return nil, err
+         }
+         // labels only at the borders of the figure
+         if i == n-1 {
+            plots[i][j].X.Label.Text = names[j]
+         }
+         if j == 0 && i > 0 {
+            plots[i][j].Y.Label.Text = names[i]
+         }
+      }
+   }
+
+   return plots, nil
+
+}
+
+
+// save a plot to filename, the format (png, svg, pdf, ...) is set by its extension
+func Save (p *plot.Plot, filename string) error {
+
+   return p.Save(Width, Height, filename)
+
+}
+
+
+// save a table of plots (nil entries are left empty) to filename in format (png, svg, pdf, ...)
+func SaveTable (plots [][]*plot.Plot, filename string, format string) error {
+
+   rows := len(plots)
+   cols := 0
+   for _, row := range plots {
+      if len(row) > cols {
+         cols = len(row)
+      }
+   }
+
+   c, err := draw.NewFormattedCanvas(vg.Length(cols) * PanelSize, vg.Length(rows) * PanelSize, format)
+   if err != nil {
+      return err
+   }
+
+   tiles := draw.Tiles{Rows: rows, Cols: cols, PadX: vg.Millimeter, PadY: vg.Millimeter}
+   canvases := plot.Align(plots, tiles, draw.New(c))
+   for i, row := range plots {
+      for j, p := range row {
+         if p != nil {
+            p.Draw(canvases[i][j])
+         }
+      }
+   }
+
+   f, err := os.Create(filename)
+   if err != nil {
+      return err
+   }
+   defer f.Close()
+
+   _, err = c.WriteTo(f)
+
+   return err
+
+}
+
+
+// (x, y) pairs, as needed by plotter. Both must have the same length
+func pairs (x []float64, y []float64) (plotter.XYs, error) {
+
+   if len(x) != len(y) {
+      return nil, fmt.Errorf("columns of different lengths: %d and %d", len(x), len(y))
+   }
+
+   xy := make(plotter.XYs, len(x))
+   for k, _ := range x {
+      xy[k].X = x[k]
+      xy[k].Y = y[k]
+   }
+
+   return xy, nil
+
+}
+
+
+// middle of each pair of consecutive edges
+func centers (edges []float64) []float64 {
+
+   c := make([]float64, len(edges)-1)
+   for k := 1; k < len(edges); k++ {
+      c[k-1] = 0.5 * (edges[k-1] + edges[k])
+   }
+
+   return c
+
+}
+
+
+// at most n values of x, taken at regular strides
+func thin (x []float64, n int) []float64 {
+
+   if n <= 0 || len(x) <= n {
+      return x
+   }
+
+   stride := int(math.Ceil(float64(len(x)) / float64(n)))
+   y := make([]float64, 0, n)
+   for k := 0; k < len(x); k += stride {
+      y = append(y, x[k])
+   }
+
+   return y
+
+}
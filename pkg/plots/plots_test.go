@@ -0,0 +1,31 @@
+package plots
+
+import (
+   "testing"
+)
+
+
+// tables without a weight column give histograms with equal weights
+func TestHistogramWithoutWeights (t *testing.T) {
+
+   _, err := Histogram([]float64{1, 2, 2, 3}, nil, 3, "x")
+   if err != nil {
+      t.Errorf("histogram without weights: %v", err)
+   }
+
+}
+
+
+// values & weights of different lengths are an error, not a panic
+func TestHistogramLengthMismatch (t *testing.T) {
+
+   _, err := Histogram([]float64{1, 2, 3}, []float64{1, 1}, 3, "x")
+   if err == nil {
+      t.Error("histogram with 3 values & 2 weights: got no error")
+   }
+   _, err = Scatter([]float64{1, 2, 3}, []float64{1}, "x", "y", false)
+   if err == nil {
+      t.Error("scatter with 3 x-values & 1 y-value: got no error")
+   }
+
+}
@@ -0,0 +1,92 @@
+// Package units provides physical quantities with a type for each dimension. Values are always
+// stored in CGS units, and other units are reached through explicit conversions, e.g.
+//
+//    m := units.Mass(1.4) * units.SolarMass
+//    m.In(units.SolarMass)    // 1.4
+package units
+
+
+// mass in grams
+type Mass float64
+
+// length in centimeters
+type Length float64
+
+// time in seconds
+type Time float64
+
+// velocity in centimeters per second
+type Velocity float64
+
+
+const (
+
+   // gravitational constant, in cm^3 g^-1 s^-2
+   G = 6.67430e-8
+
+   // speed of light, in cm/s
+   C = 2.99792458e10
+
+   // nominal solar mass parameter (IAU 2015 B3), in cm^3 s^-2
+   muSun = 1.3271244e26
+
+)
+
+
+// units of mass
+const (
+   Gram Mass = 1
+   SolarMass Mass = muSun / G
+)
+
+// units of length
+const (
+   Centimeter Length = 1
+   Kilometer Length = 1e5
+   SolarRadius Length = 6.957e10
+)
+
+// units of time
+const (
+   Second Time = 1
+   Day Time = 24.0 * 3600.0
+   Year Time = 365.25 * Day
+)
+
+// units of velocity
+const (
+   CentimeterPerSecond Velocity = 1
+   KilometerPerSecond Velocity = 1e5
+)
+
+
+// value of a mass in unit
+func (m Mass) In (unit Mass) float64 {
+
+   return float64(m / unit)
+
+}
+
+
+// value of a length in unit
+func (l Length) In (unit Length) float64 {
+
+   return float64(l / unit)
+
+}
+
+
+// value of a time in unit
+func (t Time) In (unit Time) float64 {
+
+   return float64(t / unit)
+
+}
+
+
+// value of a velocity in unit
+func (v Velocity) In (unit Velocity) float64 {
+
+   return float64(v / unit)
+
+}
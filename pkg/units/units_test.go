@@ -0,0 +1,65 @@
+package units
+
+import (
+   "math"
+   "testing"
+)
+
+
+// whether x & y agree within a relative tolerance tol
+func agree (x float64, y float64, tol float64) bool {
+
+   return math.Abs(x - y) <= tol * math.Max(math.Abs(x), math.Abs(y))
+
+}
+
+
+func TestMassIn (t *testing.T) {
+
+   m := Mass(1.4) * SolarMass
+   if got := m.In(SolarMass); !agree(got, 1.4, 1e-12) {
+      t.Errorf("1.4 Msun in Msun: got %g", got)
+   }
+   if got := SolarMass.In(Gram); !agree(got, 1.98840987e33, 1e-9) {
+      t.Errorf("1 Msun in g: got %g, want 1.98840987e33", got)
+   }
+
+}
+
+
+func TestLengthIn (t *testing.T) {
+
+   if got := SolarRadius.In(Kilometer); !agree(got, 6.957e5, 1e-12) {
+      t.Errorf("1 Rsun in km: got %g, want 6.957e5", got)
+   }
+   l := Length(3) * Kilometer
+   if got := l.In(Centimeter); !agree(got, 3e5, 1e-12) {
+      t.Errorf("3 km in cm: got %g, want 3e5", got)
+   }
+
+}
+
+
+func TestTimeIn (t *testing.T) {
+
+   if got := Year.In(Day); !agree(got, 365.25, 1e-12) {
+      t.Errorf("1 yr in days: got %g, want 365.25", got)
+   }
+   if got := Day.In(Second); !agree(got, 86400, 1e-12) {
+      t.Errorf("1 day in s: got %g, want 86400", got)
+   }
+
+}
+
+
+func TestVelocityIn (t *testing.T) {
+
+   v := Velocity(265) * KilometerPerSecond
+   if got := v.In(KilometerPerSecond); !agree(got, 265, 1e-12) {
+      t.Errorf("265 km/s in km/s: got %g", got)
+   }
+   if got := v.In(CentimeterPerSecond); !agree(got, 2.65e7, 1e-12) {
+      t.Errorf("265 km/s in cm/s: got %g, want 2.65e7", got)
+   }
+
+}
@@ -3,27 +3,34 @@ import matplotlib.pyplot as plt
 import numpy as np
 
 
+def load(filename, **kwargs):
+    """load columns of an output, skipping metadata & comments (lines starting with #)"""
+    with open(filename) as f:
+        lines = [line for line in f if not line.startswith("#")]
+    return np.loadtxt(lines, skiprows=1, unpack=True, **kwargs)
+
+
 # first, plot kick distribution between this module and a python one
-index_g, w_g, theta_g, phi_g = np.loadtxt("kicks.data", skiprows=1, unpack=True)
+index_g, w_g, theta_g, phi_g, weight_g = load("kicks.data")
 
 # kick strength
 fig, ax = plt.subplots()
-ax.hist(w_g, bins=50, histtype="step", color="C0")
+ax.hist(w_g, bins=50, weights=weight_g, histtype="step", color="C0")
 plt.show()
 
 # azimuthal angle
 fig, ax = plt.subplots()
-ax.hist(theta_g, bins=50, histtype="step", color="C0")
+ax.hist(theta_g, bins=50, weights=weight_g, histtype="step", color="C0")
 plt.show()
 
 # polar angle
 fig, ax = plt.subplots()
-ax.hist(phi_g, bins=50, histtype="step", color="C0")
+ax.hist(phi_g, bins=50, weights=weight_g, histtype="step", color="C0")
 plt.show()
 
 
 # load and compare orbit distributions
-index_g, _, _, _, p_g, a_g, e_g = np.loadtxt("orbits.data", skiprows=1, unpack=True)
+index_g, _, _, _, p_g, a_g, e_g, _, _ = load("orbits.data")
 
 fig, ax = plt.subplots()
 ax.set_xscale("log")
@@ -34,7 +41,7 @@ plt.show()
 
 
 # load and compare grid of orbits
-index_g, p_g, a_g, e_g, prob_g = np.loadtxt("grid.data", skiprows=1, unpack=True)
+index_g, p_g, a_g, e_g, prob_g = load("grid.data", usecols=range(5))
 
 fig, ax = plt.subplots()
 ax.set_xscale("log")